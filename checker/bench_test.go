@@ -0,0 +1,94 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"testing"
+)
+
+// syntheticTree returns the spec of a tree with dirs directories of
+// entries files each. Every density-th entry is a symlink instead of a
+// file, every second symlink is broken.
+func syntheticTree(dirs, entries, density int) []string {
+	var spec []string
+	links := 0
+	for d := 0; d < dirs; d++ {
+		for e := 0; e < entries; e++ {
+			path := fmt.Sprintf("d%d/e%d", d, e)
+			if e%density != 0 {
+				spec = append(spec, "file "+path)
+				continue
+			}
+			target := fmt.Sprintf("e%d", e+1)
+			if links%2 == 1 {
+				target = "missing"
+			}
+			links++
+			spec = append(spec, "link "+path+" -> "+target)
+		}
+	}
+	return spec
+}
+
+func BenchmarkCheck(b *testing.B) {
+	if runtime.GOOS == "windows" {
+		b.Skip("symbolic links need privileges on Windows")
+	}
+	for _, size := range []struct{ dirs, entries int }{{10, 100}, {100, 100}} {
+		for _, density := range []int{10, 2} {
+			root := buildTree(b, syntheticTree(size.dirs, size.entries, density)...)
+			for _, workers := range []int{1, 8} {
+				name := fmt.Sprintf("entries=%d/links=1in%d/workers=%d", size.dirs*size.entries, density, workers)
+				b.Run(name, func(b *testing.B) {
+					c := Checker{
+						Roots:   []string{root},
+						Workers: workers,
+						Stdout:  io.Discard,
+						Stderr:  io.Discard,
+					}
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						if _, err := c.Run(context.Background()); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// BenchmarkSharedTargetDir checks links which all point to files next to
+// them in the same deep directory, whose ancestors are resolved only once by
+// the directory cache.
+func BenchmarkSharedTargetDir(b *testing.B) {
+	if runtime.GOOS == "windows" {
+		b.Skip("symbolic links need privileges on Windows")
+	}
+	deep := "t/a/b/c/d/e/f/g/h/i/j"
+	var spec []string
+	for i := 0; i < 1000; i++ {
+		spec = append(spec,
+			fmt.Sprintf("file %s/f%d", deep, i),
+			fmt.Sprintf("link %s/l%d -> f%d", deep, i, i))
+	}
+	root := buildTree(b, spec...)
+	for _, cached := range []bool{true, false} {
+		b.Run(fmt.Sprintf("cache=%t", cached), func(b *testing.B) {
+			c := Checker{
+				Roots:      []string{root},
+				Stdout:     io.Discard,
+				Stderr:     io.Discard,
+				noDirCache: !cached,
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.Run(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
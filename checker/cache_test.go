@@ -0,0 +1,91 @@
+package checker
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDirCacheMatchesEvalSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the directory cache is not used on Windows")
+	}
+	tests := []struct {
+		name   string
+		tree   []string
+		link   string
+		relink func(t *testing.T, root string, c *Checker) // run between two lookups
+	}{
+		{
+			name: "file",
+			tree: []string{"file d/f", "link d/l -> f"},
+			link: "d/l",
+		},
+		{
+			name: "trailing slash",
+			tree: []string{"file d/f", "link d/slash -> f/"},
+			link: "d/slash",
+		},
+		{
+			name: "file as directory",
+			tree: []string{"file d/f", "link d/sub -> f/g"},
+			link: "d/sub",
+		},
+		{
+			name: "dot after file",
+			tree: []string{"file d/f", "link d/dot -> f/."},
+			link: "d/dot",
+		},
+		{
+			name: "relinked directory",
+			tree: []string{"file a/f", "link a/l -> f", "file b/f", "link b/l -> f", "link d -> a", "link other -> d"},
+			link: "other/l",
+			relink: func(t *testing.T, root string, c *Checker) {
+				c.ReplaceFrom, c.ReplaceTo = "a", "b"
+				var res result
+				if !c.replaceTarget(filepath.Join(root, "d"), "d", "a", &res) || res.errors > 0 {
+					t.Fatal("d was not retargeted")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := buildTree(t, tt.tree...)
+			c := &Checker{Stdout: io.Discard, Stderr: io.Discard, dirCache: newDirCache()}
+			c.logger = log.New(io.Discard, "", 0)
+			path := filepath.Join(root, filepath.FromSlash(tt.link))
+			lookups := 1
+			if tt.relink != nil {
+				lookups = 2
+			}
+			for i := 0; i < lookups; i++ {
+				if i > 0 {
+					tt.relink(t, root, c)
+				}
+				got, gotErr := c.dirCache.evalSymlinks(path)
+				want, wantErr := filepath.EvalSymlinks(path)
+				if got != want || !sameError(gotErr, wantErr) {
+					t.Errorf("lookup %d: got %q, %v, want %q, %v", i+1, got, gotErr, want, wantErr)
+				}
+			}
+		})
+	}
+}
+
+// sameError reports whether both errors are nil or both carry the same
+// underlying error number.
+func sameError(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	var ea, eb *os.PathError
+	if errors.As(a, &ea) && errors.As(b, &eb) {
+		return ea.Err == eb.Err
+	}
+	return a.Error() == b.Error()
+}
@@ -0,0 +1,1207 @@
+// Package checker traverses a directory tree recursively and searches for
+// broken symbolic links. Broken links can be reported or optionally deleted.
+package checker
+
+// see https://stackoverflow.com/questions/45022633/resolving-broken-symbolic-links
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// Checker inspects all symbolic links below Roots.
+type Checker struct {
+	Roots            []string      // directories to traverse
+	DeleteBroken     bool          // remove all broken symbolic links
+	DeleteAll        bool          // remove all symbolic links
+	Verbosity        int           // amount of messages, see LevelBroken, LevelOK and LevelDirs
+	Format           string        // report format written to stdout, one of FormatText, FormatJSON, FormatNDJSON, FormatCSV, FormatJUnit or FormatPrint0
+	Workers          int           // number of goroutines resolving links, defaults to 1
+	RootWorkers      int           // number of roots traversed concurrently, defaults to 1
+	Absolute         bool          // report absolute paths instead of paths relative to the root
+	RelativeTo       string        // report paths relative to this directory, absolute paths outside of it
+	TrimPrefix       string        // remove this prefix from the paths in messages, links are still modified at their full path
+	DryRun           bool          // only report which links would be removed
+	FixTo            string        // retarget broken links to the file with the same base name below this directory
+	ReplaceFrom      string        // retarget links, broken or not, whose target lies below this path
+	ReplaceTo        string        // replaces ReplaceFrom in the targets of the retargeted links
+	MakeRelative     bool          // rewrite healthy links with an absolute target below the root as relative links
+	MakeAbsolute     bool          // rewrite healthy links with a relative target as absolute links
+	Quarantine       string        // move broken links into this directory instead of removing them
+	TouchTargets     bool          // create empty files at the missing relative targets below the root of broken links
+	PruneEmpty       bool          // remove directories left empty by removing or quarantining links, never the roots
+	PruneEmptyAll    bool          // with PruneEmpty, also remove directories which were empty before the run
+	Follow           bool          // descend into directories symlinks point to
+	OneFileSystem    bool          // do not descend into directories on other devices than the root, like find -xdev
+	PostOrder        bool          // process the entries of a directory before the directory itself
+	ContinueOnError  bool          // log and count paths which cannot be accessed and go on, instead of failing with a WalkError
+	DedupInodes      bool          // inspect a link reachable through several paths, e.g. bind mounts, only once; not supported on Windows
+	Progress         time.Duration // print a status line to stderr at this interval, 0 disables it
+	ReportEscapes    bool          // report healthy links resolving to a path outside of the root
+	ReportEmpty      bool          // report healthy links resolving to an empty regular file, often left by a failed copy
+	ExpectFile       bool          // report healthy links resolving to anything else than a regular file, e.g. a directory or device
+	MaxResolveDepth  int           // report healthy links reached through a chain of more than this many links, 0 disables the check
+	ExpectExecutable bool          // report healthy links resolving to a regular file without execute permission; not supported on Windows
+	MinAge           time.Duration // only remove or quarantine broken links modified at least this long ago
+	Since            time.Time     // only inspect links modified after this time, the zero time disables the filter
+	MaxBroken        int           // stop the walk with ErrMaxBroken once this many broken links were found, 0 means no limit
+
+	// Sort buffers all inspected links and reports them, including the
+	// messages about broken and healthy links, sorted by path after the
+	// walk. The output no longer depends on the order of the workers, but
+	// all links are kept in memory until the end of the run.
+	Sort bool
+
+	// List only reads the targets of the links without resolving or
+	// modifying them. The text format writes "path -> target" lines to
+	// stdout, the other formats report the links with their targets.
+	List bool
+
+	// UniqueTargets logs a single message per distinct broken target with
+	// the number of links referencing it, instead of one message per
+	// broken link. Broken links are still removed one by one.
+	UniqueTargets bool
+	CountOnly     bool          // suppress all messages and report entries about single links, errors are still logged
+	OnlyBroken    bool          // report entries only for broken links, the summary still counts all links
+	Retries       int           // retry resolving and removing links this often on transient errors like ESTALE
+	Timeout       time.Duration // give up resolving a single link after this duration, 0 means no limit
+	Rate          float64       // maximum number of directory reads, link resolutions and removals per second, 0 means no limit
+	LogFormat     string        // format of log messages, LogFormatText or LogFormatLogfmt, defaults to text
+	Color         bool          // color broken links red and removed links yellow in text messages
+	Stdout        io.Writer     // receives the report, defaults to os.Stdout
+	Stderr        io.Writer     // receives messages and progress lines, defaults to os.Stderr
+
+	// Template, if not nil, is executed with each inspected Link and its
+	// output written to Stdout as a line, e.g. {{.Path}} {{.Target}}.
+	// Links with empty output, e.g. {{if .Broken}}...{{end}} for healthy
+	// links, are left out. It requires FormatText.
+	Template *template.Template
+
+	// MaxDepth limits the traversal to MaxDepth directory levels below the
+	// root, the root itself has depth 0. 0 means unlimited, a negative value
+	// limits it to the root itself.
+	MaxDepth int
+
+	// Paths relative to the root matching one of the shell patterns or
+	// regular expressions are skipped, excluded directories are not descended into.
+	// A shell pattern matches either the whole relative path or its base name.
+	Exclude       []string
+	ExcludeRegexp []*regexp.Regexp
+
+	// If Include is not empty, only symlinks matching one of the shell
+	// patterns are inspected. Excludes take precedence over includes.
+	Include []string
+
+	// SkipHidden skips the entries below the root whose name starts with
+	// a dot, like Exclude. A hidden root is still traversed.
+	SkipHidden bool
+
+	// ShouldVisit, if not nil, is called with the absolute path of each
+	// entry below the root which is not excluded by the patterns above or
+	// an ignore file. If it returns false, the entry is skipped like an
+	// excluded one, for a directory the whole subtree. An entry is thus
+	// visited only if the patterns and ShouldVisit both allow it. The roots
+	// are walked concurrently, so ShouldVisit must be safe for concurrent use.
+	ShouldVisit func(path string, d fs.DirEntry) bool
+
+	// Broken links matching one of the shell patterns in AllowBroken are
+	// expected, they are neither counted as broken nor removed. Patterns
+	// match like Include patterns, absolute patterns the absolute path.
+	AllowBroken []string
+
+	// ResolveMode is ResolveFull, the default, or ResolveTarget. With
+	// ResolveFull a link is broken if any link on the way to the final
+	// target is broken, with ResolveTarget only if its own direct target is
+	// missing. A link below a broken directory link is cascaded in both modes.
+	ResolveMode string
+
+	// FlagNetwork reports links whose absolute target lies below one of the
+	// NetworkPrefixes, e.g. "/net" or "/mnt/nfs", or is a UNC path on
+	// Windows. Such links depend on remote storage, which may be slow or
+	// unavailable. Both the target and, for healthy links, the resolved
+	// path are checked.
+	FlagNetwork     bool
+	NetworkPrefixes []string
+
+	// IgnoreFile is the name of gitignore-style files. The patterns of such
+	// a file apply to all paths below the directory it is found in, like
+	// Exclude patterns. An empty name disables ignore files.
+	IgnoreFile string
+
+	// OnBroken and OnRemoved are called for each broken and each removed
+	// link instead of logging a message. With DryRun, OnRemoved is called
+	// for the links which would be removed. The path includes the root
+	// directory, see Link.FullPath. The callbacks are never called
+	// concurrently, even with several workers.
+	OnBroken  func(path, target string, err error)
+	OnRemoved func(path string)
+
+	// PerRootStats adds the counters of each root to the summary of the
+	// JSON and NDJSON reports, see also Checker.RootStats.
+	PerRootStats bool
+
+	// RecordManifest keeps the path and target of every link not removed
+	// by the run, see Checker.Manifest and WriteManifest.
+	RecordManifest bool
+
+	// UndoLog receives a JSON line for each removed link, see UndoEntry
+	// and ReadUndoLog. It is not written to with DryRun. Directories
+	// removed by PruneEmpty are not recorded.
+	UndoLog io.Writer
+
+	fixIndex      fileIndex // files below FixTo
+	quarantineDir string    // absolute path of Quarantine
+	relativeTo    string    // absolute path of RelativeTo
+	progress      *progress
+	pruner        *pruner         // only set with PruneEmpty
+	links         *linkSet        // only set with DedupInodes
+	limiter       *limiter        // throttles the file system operations to Rate
+	dirCache      *dirCache       // resolved target directories, nil on Windows
+	noDirCache    bool            // resolve every link with filepath.EvalSymlinks, for benchmarks
+	walkErrors    []int64         // per root, paths skipped with ContinueOnError and roots which cannot be entered, updated atomically
+	walkDenied    []int64         // per root, walkErrors due to missing permissions
+	brokenSeen    *int64          // broken links reported with MaxBroken, updated atomically
+	stopWalk      func()          // cancels the walks once MaxBroken is reached
+	logger        *log.Logger     // writes to Stderr
+	broken        []BrokenLink    // found by the last run
+	manifest      []ManifestEntry // links of the last run with RecordManifest
+	rootStats     []RootStats     // of the last run, in the order of Roots
+}
+
+// Stats holds the counters of a run.
+type Stats struct {
+	LinksInspected   int `json:"links_inspected"`
+	LinksRemoved     int `json:"links_removed"`
+	BrokenLinks      int `json:"broken_links"`
+	CircularLinks    int `json:"circular_links"`         // broken links caused by a cycle, included in BrokenLinks
+	SelfLinks        int `json:"self_referential_links"` // broken links pointing to themselves, included in BrokenLinks but not in CircularLinks
+	FixedLinks       int `json:"fixed_links"`
+	ConvertedLinks   int `json:"converted_links"`
+	RetargetedLinks  int `json:"retargeted_links"` // links retargeted from Checker.ReplaceFrom to Checker.ReplaceTo
+	QuarantinedLinks int `json:"quarantined_links"`
+	Placeholders     int `json:"placeholders_created"` // empty files created with Checker.TouchTargets
+	EscapingLinks    int `json:"escaping_links"`
+	EmptyTargetLinks int `json:"empty_target_links"`
+	TypeMismatches   int `json:"type_mismatch_links"`         // healthy links not resolving to a regular file with Checker.ExpectFile
+	NetworkLinks     int `json:"network_target_links"`        // links to network paths with Checker.FlagNetwork
+	OverDepthLinks   int `json:"over_depth_links"`            // healthy links with a chain longer than Checker.MaxResolveDepth
+	NonExecutable    int `json:"non_executable_target_links"` // healthy links resolving to a file without execute bit with Checker.ExpectExecutable
+	CascadedLinks    int `json:"cascaded_links"`              // broken only because of another broken link, not included in BrokenLinks
+	AllowedLinks     int `json:"allowed_broken_links"`        // broken links matching Checker.AllowBroken, not included in BrokenLinks
+	TimedOutLinks    int `json:"timed_out_links"`             // could not be resolved within Checker.Timeout
+	Errors           int `json:"errors"`
+	PermissionErrors int `json:"permission_errors"` // links not modified for lack of permission on the parent directory and directories not readable with Checker.ContinueOnError, included in Errors
+	PrunedDirs       int `json:"pruned_dirs"`       // empty directories removed with Checker.PruneEmpty
+	DuplicateLinks   int `json:"duplicate_links"`   // links skipped with Checker.DedupInodes, not included in LinksInspected
+
+	// Length of the targets of all inspected links in bytes, as returned
+	// by os.Readlink. Some file systems store short targets in the inode.
+	TargetBytes     int `json:"target_bytes"`
+	MinTargetLength int `json:"min_target_length"`
+	MaxTargetLength int `json:"max_target_length"`
+}
+
+// RootStats holds the counters of the links found below a single root.
+// Errors not related to a single link are only counted in the total.
+type RootStats struct {
+	Root string `json:"root"` // as given in Checker.Roots
+	Stats
+}
+
+// rootDir is a root directory being traversed.
+type rootDir struct {
+	name  string // as given in Checker.Roots
+	abs   string // absolute path
+	real  string // absolute path with all symlinks resolved
+	file  bool   // the root was a single link, this is its parent directory
+	index int    // position in Checker.Roots
+}
+
+// Link describes an inspected symbolic link.
+type Link struct {
+	Root          string `json:"root"`        // root directory the link was found in
+	Path          string `json:"path"`        // relative to Root, or absolute if Checker.Absolute is set
+	Target        string `json:"target"`      // raw contents of the link
+	TargetPath    string `json:"target_path"` // Target resolved against the directory of the link, relative to Root like Path
+	Resolved      string `json:"resolved"`    // empty if the link is broken
+	Broken        bool   `json:"broken"`
+	Reason        string `json:"reason,omitempty"` // why the link is broken, ReasonMissingTarget, ReasonCycle or ReasonSelf
+	Error         string `json:"error,omitempty"`  // resolution error of a broken or allowed link
+	Removed       bool   `json:"removed"`
+	Fixed         bool   `json:"fixed"`
+	Converted     bool   `json:"converted"`
+	Retargeted    bool   `json:"retargeted"` // target prefix replaced with Checker.ReplaceFrom and ReplaceTo
+	Quarantined   bool   `json:"quarantined"`
+	Placeholder   bool   `json:"placeholder"`    // an empty file was created at the target
+	Escapes       bool   `json:"escapes"`        // resolves to a path outside of the root
+	EmptyTarget   bool   `json:"empty_target"`   // resolves to a zero-byte regular file
+	TypeMismatch  bool   `json:"type_mismatch"`  // resolves to something else than a regular file with Checker.ExpectFile
+	NetworkTarget bool   `json:"network_target"` // points to a network path with Checker.FlagNetwork
+	OverDepth     bool   `json:"over_depth"`     // resolves through a chain of more than Checker.MaxResolveDepth links
+	NotExecutable bool   `json:"not_executable"` // resolves to a regular file without execute bit with Checker.ExpectExecutable
+	Cascaded      bool   `json:"cascaded"`       // target lies below a broken link, which is reported instead
+	Allowed       bool   `json:"allowed"`        // broken, but matches Checker.AllowBroken
+	TimedOut      bool   `json:"timed_out"`      // resolution did not finish within Checker.Timeout
+}
+
+// FullPath returns the path of the link including its root directory.
+func (l Link) FullPath() string {
+	if filepath.IsAbs(l.Path) {
+		return l.Path
+	}
+	return filepath.Join(l.Root, l.Path)
+}
+
+// BrokenLink is a link which could not be resolved.
+type BrokenLink struct {
+	Path   string // including the root directory, see Link.FullPath
+	Target string // raw contents of the link
+	Err    error  // why the link could not be resolved
+}
+
+// result is the outcome of checking a single link.
+type result struct {
+	link    Link
+	name    string // path as shown in messages
+	cause   string // broken ancestor of a cascaded link, as shown in messages
+	errors  int    // number of failed operations
+	denied  int    // failed operations due to missing permissions, included in errors
+	err     error  // resolution error of a broken link
+	root    int    // index of the root in Checker.Roots
+	dropped bool   // a broken link beyond MaxBroken, neither reported nor counted
+
+	messages []byte // logged while checking the link with Checker.Sort, written by the collector
+}
+
+// add counts the result.
+func (s *Stats) add(res result) {
+	s.LinksInspected++
+	n := len(res.link.Target)
+	s.TargetBytes += n
+	if s.LinksInspected == 1 || n < s.MinTargetLength {
+		s.MinTargetLength = n
+	}
+	if n > s.MaxTargetLength {
+		s.MaxTargetLength = n
+	}
+	if res.link.Broken {
+		s.BrokenLinks++
+	}
+	if res.link.Broken && res.link.Reason == ReasonCycle {
+		s.CircularLinks++
+	}
+	if res.link.Broken && res.link.Reason == ReasonSelf {
+		s.SelfLinks++
+	}
+	if res.link.Removed {
+		s.LinksRemoved++
+	}
+	if res.link.Fixed {
+		s.FixedLinks++
+	}
+	if res.link.Converted {
+		s.ConvertedLinks++
+	}
+	if res.link.Retargeted {
+		s.RetargetedLinks++
+	}
+	if res.link.Quarantined {
+		s.QuarantinedLinks++
+	}
+	if res.link.Placeholder {
+		s.Placeholders++
+	}
+	if res.link.Escapes {
+		s.EscapingLinks++
+	}
+	if res.link.EmptyTarget {
+		s.EmptyTargetLinks++
+	}
+	if res.link.TypeMismatch {
+		s.TypeMismatches++
+	}
+	if res.link.NotExecutable {
+		s.NonExecutable++
+	}
+	if res.link.OverDepth {
+		s.OverDepthLinks++
+	}
+	if res.link.NetworkTarget {
+		s.NetworkLinks++
+	}
+	if res.link.Cascaded {
+		s.CascadedLinks++
+	}
+	if res.link.Allowed {
+		s.AllowedLinks++
+	}
+	if res.link.TimedOut {
+		s.TimedOutLinks++
+	}
+	s.Errors += res.errors
+	s.PermissionErrors += res.denied
+}
+
+// Run traverses the root directories and inspects every symbolic link
+// found. Up to RootWorkers roots are traversed concurrently, their links
+// are resolved by the same workers. The counters of all roots are
+// aggregated. If the traversal of a root fails, no further roots are
+// started and the error of the first failing root is returned.
+//
+// The traversal stops when ctx is done. Links already handed to the
+// workers are still processed, so that no operation is interrupted
+// halfway. The partial Stats gathered so far are returned along with the
+// error of ctx, e.g. context.Canceled or context.DeadlineExceeded.
+func (c *Checker) Run(ctx context.Context) (Stats, error) {
+	var stats Stats
+	if c.DeleteBroken && c.DeleteAll {
+		return stats, errors.New("DeleteBroken and DeleteAll are not allowed together")
+	}
+	if (c.MakeRelative || c.MakeAbsolute) && (c.DeleteBroken || c.DeleteAll) {
+		return stats, errors.New("MakeRelative and MakeAbsolute are not allowed together with DeleteBroken or DeleteAll")
+	}
+	if c.List && (c.DeleteBroken || c.DeleteAll || c.MakeRelative || c.MakeAbsolute || c.FixTo != "" || c.ReplaceFrom != "" || c.Quarantine != "" || c.PruneEmpty) {
+		return stats, errors.New("List is not allowed together with options modifying links")
+	}
+	if c.MakeRelative && c.MakeAbsolute {
+		return stats, errors.New("MakeRelative and MakeAbsolute are not allowed together")
+	}
+	if c.ReplaceFrom != "" && c.DeleteAll {
+		return stats, errors.New("ReplaceFrom is not allowed together with DeleteAll")
+	}
+	if c.Quarantine != "" && (c.DeleteBroken || c.DeleteAll) {
+		return stats, errors.New("Quarantine is not allowed together with DeleteBroken or DeleteAll")
+	}
+	if c.TouchTargets && (c.DeleteBroken || c.DeleteAll || c.Quarantine != "") {
+		return stats, errors.New("TouchTargets is not allowed together with DeleteBroken, DeleteAll or Quarantine")
+	}
+	if c.Template != nil && c.Format != "" && c.Format != FormatText {
+		return stats, fmt.Errorf("Template is not allowed together with format %s", c.Format)
+	}
+	switch c.ResolveMode {
+	case "", ResolveFull, ResolveTarget:
+	default:
+		return stats, fmt.Errorf("unknown resolve mode %q", c.ResolveMode)
+	}
+	switch c.LogFormat {
+	case "", LogFormatText, LogFormatLogfmt:
+	default:
+		return stats, fmt.Errorf("unknown log format %q", c.LogFormat)
+	}
+	if err := validateGlobs(c.Exclude); err != nil {
+		return stats, err
+	}
+	if err := validateGlobs(c.Include); err != nil {
+		return stats, err
+	}
+	if err := validateGlobs(c.AllowBroken); err != nil {
+		return stats, err
+	}
+	stdout, stderr := c.Stdout, c.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	tty := isTerminal(stderr)
+	stderr = &syncWriter{w: stderr} // shared by the logger and the progress line
+	flags := log.LstdFlags
+	if c.LogFormat == LogFormatLogfmt {
+		flags = 0 // each line has its own time key
+	}
+	c.logger = log.New(stderr, "", flags)
+
+	rep, err := newReporter(c.Format, stdout, c.Template, c.CountOnly, c.OnlyBroken, c.List)
+	if err != nil {
+		return stats, err
+	}
+
+	if c.FixTo != "" {
+		c.fixIndex, err = buildFileIndex(c.FixTo)
+		if err != nil {
+			return stats, fmt.Errorf("could not index %s: %w", c.FixTo, err)
+		}
+	}
+
+	if c.Quarantine != "" {
+		c.quarantineDir, err = filepath.Abs(c.Quarantine)
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	c.relativeTo = ""
+	if c.RelativeTo != "" {
+		c.relativeTo, err = filepath.Abs(c.RelativeTo)
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	roots := make([]rootDir, len(c.Roots))
+	for i, root := range c.Roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return stats, err
+		}
+		real, err := filepath.EvalSymlinks(abs)
+		if err != nil {
+			real = abs // reported by walk
+		}
+		roots[i] = rootDir{name: root, abs: abs, real: real, index: i}
+		if c.relativeTo != "" && !within(c.relativeTo, abs) {
+			c.logf(LevelBroken, "warning: %s is not below %s, reporting absolute paths", root, c.RelativeTo)
+		}
+	}
+
+	c.progress = &progress{}
+	c.broken = nil
+	c.manifest = nil
+	c.rootStats = make([]RootStats, len(c.Roots))
+	for i, root := range c.Roots {
+		c.rootStats[i].Root = root
+	}
+	c.pruner = nil
+	if c.PruneEmpty {
+		c.pruner = newPruner()
+	}
+	c.walkErrors, c.walkDenied = make([]int64, len(c.Roots)), make([]int64, len(c.Roots))
+	c.limiter = newLimiter(c.Rate)
+	c.dirCache = nil
+	if runtime.GOOS != "windows" && !c.noDirCache {
+		c.dirCache = newDirCache()
+	}
+	c.links = nil
+	if c.DedupInodes {
+		c.links = newLinkSet()
+	}
+	if c.Progress > 0 {
+		c.progress.start(stderr, tty, c.Progress)
+		defer c.progress.finish()
+	}
+
+	// The walks feed the symlinks to the workers, the results are collected
+	// in a single goroutine so that stats and reporter need no locking.
+	jobs := make(chan job)
+	results := make(chan result)
+	walkCtx, cancel := context.WithCancel(ctx) // canceled by the first failing root or MaxBroken
+	defer cancel()
+	c.brokenSeen, c.stopWalk = new(int64), cancel
+	var wg sync.WaitGroup
+	for i := 0; i < c.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if c.MaxBroken > 0 && walkCtx.Err() != nil {
+					continue // the walk was stopped, drop the queued links
+				}
+				if c.Sort {
+					results <- c.checkBuffered(j.root, j.path)
+				} else {
+					results <- c.check(j.root, j.path)
+				}
+			}
+		}()
+	}
+	targets := make(map[string]*targetCount) // only used with UniqueTargets
+	collect := func(res result) {
+		stats.add(res)
+		c.rootStats[res.root].add(res)
+		if res.link.Broken && c.UniqueTargets {
+			t, _ := filepath.Abs(targetPath(res.link.FullPath(), res.link.Target))
+			if targets[t] == nil {
+				targets[t] = &targetCount{target: t}
+			}
+			targets[t].links++
+			if res.link.Removed {
+				targets[t].removed++
+			}
+		}
+		if res.link.Broken {
+			c.broken = append(c.broken, BrokenLink{Path: res.link.FullPath(), Target: res.link.Target, Err: res.err})
+			if c.OnBroken != nil {
+				c.OnBroken(res.link.FullPath(), res.link.Target, res.err)
+			}
+		}
+		if (res.link.Removed || res.link.Quarantined) && c.pruner != nil {
+			abs, _ := filepath.Abs(res.link.FullPath())
+			c.pruner.removed(abs, res.name)
+		}
+		if c.RecordManifest {
+			c.record(res.link)
+		}
+		if res.link.Removed && c.OnRemoved != nil {
+			c.OnRemoved(res.link.FullPath())
+		}
+		if res.link.Removed && !c.DryRun && c.UndoLog != nil {
+			if err := c.writeUndo(res.link); err != nil {
+				stats.Errors++
+				c.rootStats[res.root].Errors++
+				c.errorf("Could not write undo log: %v", err)
+			}
+		}
+		rep.add(res.link)
+	}
+	collected := make(chan struct{})
+	go func() {
+		var sorted []result
+		for res := range results {
+			if res.dropped {
+				continue
+			}
+			atomic.AddInt64(&c.progress.inspected, 1)
+			if res.link.Broken {
+				atomic.AddInt64(&c.progress.broken, 1)
+			}
+			if c.Sort {
+				sorted = append(sorted, res)
+				continue
+			}
+			collect(res)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i].link.FullPath() < sorted[j].link.FullPath()
+		})
+		for _, res := range sorted {
+			c.logger.Writer().Write(res.messages)
+			collect(res)
+		}
+		close(collected)
+	}()
+
+	// The first failing root cancels the walks of the others. Roots which
+	// cannot be read for lack of permission are only logged and counted.
+	errs := make([]error, len(roots))
+	sem := make(chan struct{}, c.rootWorkers())
+	var walks sync.WaitGroup
+	for i, root := range roots {
+		sem <- struct{}{}
+		if walkCtx.Err() != nil {
+			break
+		}
+		walks.Add(1)
+		go func(i int, root rootDir) {
+			defer func() {
+				<-sem
+				walks.Done()
+			}()
+			if err := c.walk(walkCtx, root, jobs); err != nil {
+				errs[i] = err
+				cancel()
+			}
+		}(i, root)
+	}
+	walks.Wait()
+	close(jobs)
+	wg.Wait()
+	close(results)
+	<-collected
+	c.logTargets(targets)
+	if c.links != nil {
+		stats.DuplicateLinks = c.links.dups
+	}
+	for i := range roots {
+		stats.Errors += int(c.walkErrors[i])
+		stats.PermissionErrors += int(c.walkDenied[i])
+		c.rootStats[i].Errors += int(c.walkErrors[i])
+		c.rootStats[i].PermissionErrors += int(c.walkDenied[i])
+	}
+	if c.pruner != nil && walkCtx.Err() == nil {
+		pruned, errs := c.prune(roots)
+		stats.PrunedDirs += pruned
+		stats.Errors += errs
+	}
+
+	if ctx.Err() != nil {
+		// still write a valid report of the partial run
+		rep.finish(stats, c.reportedRootStats())
+		return stats, ctx.Err()
+	}
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return stats, err
+		}
+	}
+	if err := rep.finish(stats, c.reportedRootStats()); err != nil {
+		return stats, fmt.Errorf("could not write report: %w", err)
+	}
+	if c.MaxBroken > 0 && atomic.LoadInt64(c.brokenSeen) >= int64(c.MaxBroken) {
+		return stats, ErrMaxBroken
+	}
+	return stats, nil
+}
+
+// job is a symbolic link to be checked by a worker.
+type job struct {
+	root rootDir
+	path string // relative to the root
+}
+
+// walk traverses a single root directory and sends the symlinks found to jobs.
+func (c *Checker) walk(ctx context.Context, root rootDir, jobs chan<- job) error {
+	fi, err := os.Lstat(root.abs)
+	if errors.Is(err, fs.ErrPermission) {
+		// like a root which cannot be entered, see walkFn
+		c.errorf("Could not read root-dir %s: %v", root.name, err)
+		atomic.AddInt64(&c.walkErrors[root.index], 1)
+		atomic.AddInt64(&c.walkDenied[root.index], 1)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read root-dir %s: %w", root.name, err)
+	}
+	if fi.Mode()&fs.ModeSymlink != 0 {
+		if target, err := os.Stat(root.abs); err == nil && target.IsDir() {
+			fi = target // traverse the directory the root points to
+		}
+	}
+	if !fi.IsDir() {
+		c.checkFile(root, fi, jobs)
+		return nil
+	}
+	if c.Absolute {
+		c.logf(LevelDirs, "root dir: %s", root.abs)
+	} else {
+		c.logf(LevelDirs, "root dir: %s", root.name)
+	}
+
+	// Traverse directory recursive, does not follow links unless Follow is set.
+	// WalkDir does not stat every entry, the type bits are sufficient to detect symlinks.
+	visited := make(map[fileID]bool) // directories entered, only used with Follow
+	rootDev, haveDev := getDevice(root.abs, fi)
+	ign := newIgnores(root.abs, c.IgnoreFile)
+	var walkFn fs.WalkDirFunc
+	var walkTree func(string) error
+	leave := func(path string) {
+		if rel, err := filepath.Rel(root.abs, filepath.Clean(path)); err == nil {
+			c.logf(LevelDirs, "visited dir: %q", rel)
+		}
+	}
+	walkTree = func(dir string) error {
+		if c.PostOrder {
+			return walkPostOrder(dir, walkFn, leave)
+		}
+		return filepath.WalkDir(dir, walkFn)
+	}
+	walkFn = func(path string, d fs.DirEntry, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		path = filepath.Clean(path) // the root of a followed link has a trailing separator
+		rel, relErr := filepath.Rel(root.abs, path)
+		if relErr != nil {
+			return relErr
+		}
+		atomic.AddInt64(&c.progress.scanned, 1)
+		if err != nil {
+			werr := &WalkError{Path: c.display(root, rel), Err: err}
+			c.errorf("%v", werr)
+			// A root which cannot be entered never stops the other roots.
+			if !c.ContinueOnError && rel != "." {
+				return werr
+			}
+			atomic.AddInt64(&c.walkErrors[root.index], 1)
+			if errors.Is(err, fs.ErrPermission) {
+				atomic.AddInt64(&c.walkDenied[root.index], 1)
+			}
+			return nil // skip the entry, WalkDir goes on with the next one
+		}
+
+		if rel != "." && (c.excluded(rel) || ign.ignored(rel, d.IsDir()) ||
+			c.ShouldVisit != nil && !c.ShouldVisit(path, d)) {
+			c.logf(LevelDirs, "excluded: %q", rel)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Junctions on Windows may be reported as directories
+		link := isLink(path, d)
+
+		if d.IsDir() && !link {
+			if c.quarantineDir != "" && path == c.quarantineDir {
+				c.logf(LevelDirs, "skip quarantine dir: %q", rel)
+				return filepath.SkipDir
+			}
+			if c.OneFileSystem && haveDev && !c.onDevice(path, d, rootDev) {
+				c.logf(LevelDirs, "skip %q: other file system", rel)
+				return filepath.SkipDir
+			}
+			if c.Follow && !c.enterDir(path, c.display(root, rel), d, visited) {
+				return filepath.SkipDir
+			}
+			if !c.PostOrder {
+				c.logf(LevelDirs, "visited dir: %q", rel)
+			}
+			c.limiter.wait() // before WalkDir reads the directory
+			if c.PruneEmptyAll && c.pruner != nil {
+				c.pruner.add(path, c.display(root, rel))
+			}
+			if err := ign.enter(rel); err != nil {
+				c.errorf("Could not read ignore file: %v", err)
+			}
+			if max := c.maxDepth(); max >= 0 && depth(rel) >= max {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// If path is a symlink
+		if link {
+			if c.included(rel) && c.modifiedSince(d) && c.firstVisit(path, c.display(root, rel), d) {
+				jobs <- job{root, rel}
+			}
+			if c.Follow {
+				err = follow(path, walkTree)
+			}
+			if err == nil && d.IsDir() {
+				return filepath.SkipDir // never descend into a junction itself
+			}
+			return err
+		}
+
+		return nil
+	}
+	// The trailing separator makes the walk descend into a root which is a
+	// symlink to a directory.
+	return walkTree(root.abs + string(filepath.Separator))
+}
+
+// checkFile sends a root which is not a directory to the workers if it
+// is a symbolic link. Regular files contain no links and are skipped.
+func (c *Checker) checkFile(root rootDir, fi fs.FileInfo, jobs chan<- job) {
+	atomic.AddInt64(&c.progress.scanned, 1)
+	if !isLink(root.abs, fs.FileInfoToDirEntry(fi)) {
+		c.logf(LevelDirs, "skip %s: not a directory or symbolic link", root.name)
+		return
+	}
+	if _, err := os.Stat(root.abs); err != nil {
+		target, _ := os.Readlink(root.abs)
+		c.logf(LevelBroken, "root %s is a broken symlink pointing to %s", root.name, target)
+	}
+	parent := rootDir{name: filepath.Dir(root.name), abs: filepath.Dir(root.abs), file: true, index: root.index}
+	parent.real, _ = filepath.EvalSymlinks(parent.abs)
+	if parent.real == "" {
+		parent.real = parent.abs
+	}
+	jobs <- job{parent, filepath.Base(root.abs)}
+}
+
+// depth returns the number of levels path is below the root.
+func depth(path string) int {
+	if path == "." {
+		return 0
+	}
+	return strings.Count(path, string(filepath.Separator)) + 1
+}
+
+// BrokenLinks returns the broken links found by the last run in the
+// order they were inspected. It must not be called during a run.
+func (c *Checker) BrokenLinks() []BrokenLink {
+	return c.broken
+}
+
+// RootStats returns the counters of the last run for each root, in the
+// order of Roots. It must not be called during a run.
+func (c *Checker) RootStats() []RootStats {
+	return c.rootStats
+}
+
+// reportedRootStats returns the counters per root for the report, or nil
+// if PerRootStats is not set.
+func (c *Checker) reportedRootStats() []RootStats {
+	if !c.PerRootStats {
+		return nil
+	}
+	return c.rootStats
+}
+
+// maxDepth returns the deepest directory level which is entered, or -1
+// without a limit.
+func (c *Checker) maxDepth() int {
+	switch {
+	case c.MaxDepth == 0:
+		return -1
+	case c.MaxDepth < 0:
+		return 0
+	}
+	return c.MaxDepth
+}
+
+func (c *Checker) workers() int {
+	if c.Workers < 1 {
+		return 1
+	}
+	return c.Workers
+}
+
+func (c *Checker) rootWorkers() int {
+	if c.RootWorkers < 1 {
+		return 1
+	}
+	return c.RootWorkers
+}
+
+// check inspects the symbolic link rel below root. It is safe for concurrent use.
+func (c *Checker) check(root rootDir, rel string) result {
+	path := filepath.Join(root.abs, rel)
+	name := c.display(root, rel)
+	res := result{link: Link{Root: root.name, Path: rel}, name: name, root: root.index}
+	if c.Absolute {
+		// the real path, TrimPrefix only applies to the messages
+		res.link.Path = path
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		res.errors++
+		c.errorf("Could not read link %s: %v", name, err)
+	}
+	res.link.Target = target
+	if err == nil {
+		res.link.TargetPath = targetPath(res.link.Path, target)
+	}
+	if c.List {
+		return res
+	}
+
+	// remove link anyway
+	if c.DeleteAll {
+		c.remove(path, name, "link", &res)
+		return res
+	}
+
+	if c.FlagNetwork && target != "" {
+		abs := target
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(filepath.Dir(path), target)
+		}
+		c.reportNetwork(name, abs, &res)
+	}
+
+	// check if link is broken
+	var resolvedPath string
+	err = c.retry("resolve", name, func() (err error) {
+		resolvedPath, err = c.evalSymlinks(path)
+		return err
+	})
+	if errors.Is(err, errTimeout) {
+		// neither broken nor healthy, never removed
+		if c.LogFormat == LogFormatLogfmt {
+			c.logger.Print(Logfmt("error", "timeout", "path", name, "target", target, "timeout", c.Timeout))
+		} else {
+			c.logger.Printf("Could not resolve %s -> %s: timed out after %s", name, target, c.Timeout)
+		}
+		res.link.TimedOut = true
+		return res
+	}
+	if err != nil {
+		ancestor := brokenAncestor(root.abs, path, target)
+		if rel, err := filepath.Rel(root.abs, ancestor); ancestor != "" && err == nil && c.reportsBroken(root, rel) {
+			// only the ancestor is reported as broken and possibly removed
+			res.link.Cascaded = true
+			res.cause = c.display(root, rel)
+			c.logLink(res)
+			return res
+		}
+		if c.allowedBroken(root, rel) {
+			res.link.Allowed = true
+			res.link.Reason = brokenReason(path)
+			res.err = err
+			res.link.Error = err.Error()
+			c.logLink(res)
+			return res
+		}
+		if !c.reserveBroken() {
+			// found by a worker after the walk was stopped
+			res.dropped = true
+			return res
+		}
+		res.link.Broken = true
+		res.link.Reason = brokenReason(path)
+		res.err = err
+		res.link.Error = err.Error()
+		c.logLink(res)
+		if c.ReplaceFrom != "" && c.replaceTarget(path, name, target, &res) {
+			return res
+		}
+		if c.FixTo != "" && res.link.Reason == ReasonMissingTarget && c.fix(path, name, target, &res) {
+			return res
+		}
+		if c.TouchTargets && res.link.Reason == ReasonMissingTarget {
+			c.touchTarget(root, path, name, target, &res)
+			return res
+		}
+		if (c.Quarantine != "" || c.DeleteBroken) && !c.oldEnough(path, name, &res) {
+			return res
+		}
+		switch {
+		case c.Quarantine != "":
+			c.quarantine(root, path, rel, name, target, &res)
+		case c.DeleteBroken:
+			c.remove(path, name, "broken link", &res)
+		}
+	} else {
+		if c.ReportEscapes {
+			c.reportEscape(root, name, resolvedPath, &res)
+		}
+		if c.ExpectFile {
+			c.reportType(path, name, resolvedPath, &res)
+		}
+		if c.FlagNetwork {
+			c.reportNetwork(name, resolvedPath, &res)
+		}
+		if c.MaxResolveDepth > 0 {
+			c.reportDepth(path, name, resolvedPath, &res)
+		}
+		if c.ExpectExecutable && runtime.GOOS != "windows" {
+			c.reportNotExecutable(path, name, resolvedPath, &res)
+		}
+		if c.ReportEmpty {
+			c.reportEmpty(path, name, resolvedPath, &res)
+		}
+		if !c.Absolute && within(root.real, resolvedPath) {
+			// relative to the root like the path of the link
+			resolvedPath, _ = filepath.Rel(root.real, resolvedPath)
+		}
+		res.link.Resolved = resolvedPath
+		c.logLink(res)
+		if c.ReplaceFrom != "" && c.replaceTarget(path, name, target, &res) {
+			return res
+		}
+		switch {
+		case c.MakeRelative:
+			c.makeRelative(root, path, name, target, &res)
+		case c.MakeAbsolute:
+			c.makeAbsolute(root, path, name, target, &res)
+		}
+	}
+	return res
+}
+
+// reserveBroken counts a broken link towards MaxBroken before it is reported
+// or changed, and stops the walk with the last allowed one. It reports
+// whether the link is within the limit, the links the concurrent workers
+// find beyond it are dropped.
+func (c *Checker) reserveBroken() bool {
+	if c.MaxBroken <= 0 {
+		return true
+	}
+	n := atomic.AddInt64(c.brokenSeen, 1)
+	if n == int64(c.MaxBroken) {
+		c.stopWalk()
+	}
+	return n <= int64(c.MaxBroken)
+}
+
+// checkBuffered is check with the messages about the link logged into the
+// result instead of Stderr, so that the collector can write them in sorted
+// order. It checks on a copy of c with its own logger, all other state is
+// shared through pointers.
+func (c *Checker) checkBuffered(root rootDir, rel string) result {
+	var buf bytes.Buffer
+	cc := *c
+	cc.logger = log.New(&buf, c.logger.Prefix(), c.logger.Flags())
+	res := cc.check(root, rel)
+	res.messages = buf.Bytes()
+	return res
+}
+
+// modifiedSince reports whether the link d was modified after Since.
+// Links which cannot be stat'ed are inspected anyway.
+func (c *Checker) modifiedSince(d fs.DirEntry) bool {
+	if c.Since.IsZero() {
+		return true
+	}
+	fi, err := d.Info()
+	if err != nil {
+		return true
+	}
+	return fi.ModTime().After(c.Since)
+}
+
+// targetCount counts the broken links referencing a target.
+type targetCount struct {
+	target         string // absolute, resolved against the directory of the links
+	links, removed int
+}
+
+// logTargets logs the broken targets, the most referenced first.
+func (c *Checker) logTargets(targets map[string]*targetCount) {
+	counts := make([]*targetCount, 0, len(targets))
+	for _, t := range targets {
+		counts = append(counts, t)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].links != counts[j].links {
+			return counts[i].links > counts[j].links
+		}
+		return counts[i].target < counts[j].target
+	})
+	for _, t := range counts {
+		kv := fields{"target", t.target, "links", t.links, "removed", t.removed}
+		if t.removed > 0 {
+			c.event(LevelBroken, "broken_target", kv, "broken target %s referenced by %d links, %d removed", t.target, t.links, t.removed)
+		} else {
+			c.event(LevelBroken, "broken_target", kv, "broken target %s referenced by %d links", t.target, t.links)
+		}
+	}
+}
+
+// logLink logs whether the link of res is broken or healthy. Links which
+// were not resolved, e.g. with DeleteAll, are not logged.
+func (c *Checker) logLink(res result) {
+	link := res.link
+	switch {
+	case link.Cascaded:
+		c.event(LevelOK, "cascaded", fields{"path", res.name, "target", link.Target, "cause", res.cause},
+			"skip broken link %s -> %s: caused by the broken link %s", res.name, link.Target, res.cause)
+	case link.Allowed:
+		c.event(LevelOK, "allowed", fields{"path", res.name, "target", link.Target, "reason", link.Reason, "err", res.err},
+			"allowed broken link %s -> %s: %v", res.name, link.Target, res.err)
+	case link.Broken && (c.OnBroken != nil || c.UniqueTargets):
+		// reported by the collector
+	case link.Broken && c.Format == FormatPrint0:
+		// the path is written to stdout
+	case link.Reason == ReasonSelf:
+		c.event(LevelBroken, "broken", fields{"path", res.name, "target", link.Target, "target_path", link.TargetPath, "reason", link.Reason, "err", res.err},
+			"broken link %s -> %s: points to itself", res.name, link.Target)
+	case link.Reason == ReasonCycle:
+		c.event(LevelBroken, "broken", fields{"path", res.name, "target", link.Target, "target_path", link.TargetPath, "reason", link.Reason, "err", res.err},
+			"broken link %s -> %s: circular reference", res.name, displayTarget(res.name, link.Target))
+	case link.Broken:
+		c.event(LevelBroken, "broken", fields{"path", res.name, "target", link.Target, "target_path", link.TargetPath, "reason", link.Reason, "err", res.err},
+			"broken link %s -> %s: %v", res.name, displayTarget(res.name, link.Target), res.err)
+	case link.Resolved != "":
+		c.event(LevelOK, "ok", fields{"path", res.name, "target", link.Target, "resolved", link.Resolved},
+			"symlink %s OK", link.Resolved)
+	}
+}
+
+// oldEnough reports whether the link path was modified at least MinAge ago.
+// Younger links may only be broken temporarily, e.g. during a deployment.
+func (c *Checker) oldEnough(path, name string, res *result) bool {
+	if c.MinAge <= 0 {
+		return true
+	}
+	fi, err := os.Lstat(path)
+	if err != nil {
+		res.errors++
+		c.errorf("Could not get stat for %s: %v", name, err)
+		return false
+	}
+	if age := time.Since(fi.ModTime()); age < c.MinAge {
+		c.logf(LevelBroken, "Not removing broken link %s: modified %s ago, younger than %s", name, age.Round(time.Second), c.MinAge)
+		return false
+	}
+	return true
+}
+
+// remove deletes the link path and records the outcome in res.
+// With DryRun the link is left alone and only reported.
+func (c *Checker) remove(path, name, kind string, res *result) {
+	if c.DryRun {
+		if c.OnRemoved == nil {
+			c.event(LevelBroken, "removed", fields{"path", name, "kind", kind, "dry_run", true}, "would remove %s", name)
+		}
+		res.link.Removed = true
+		return
+	}
+	if _, err := os.Lstat(filepath.Dir(path)); c.permissionDenied("remove", name, err, res) {
+		return
+	}
+	if err := c.retry("remove", name, func() error {
+		c.limiter.wait()
+		return os.Remove(path)
+	}); err != nil {
+		if !c.permissionDenied("remove", name, err, res) {
+			res.errors++
+			c.errorf("Could not remove %s %s: %v", kind, name, err)
+		}
+		return
+	}
+	c.dirCache.invalidate()
+	res.link.Removed = true
+	if c.OnRemoved == nil && !(c.UniqueTargets && res.link.Broken) {
+		c.event(LevelBroken, "removed", fields{"path", name, "kind", kind}, "Removed %s %s", kind, name)
+	}
+}
+
+// permissionDenied reports whether err is caused by missing permissions on
+// the parent directory of the link name and records the failure of the
+// operation what. All other errors are left to the caller.
+func (c *Checker) permissionDenied(what, name string, err error, res *result) bool {
+	if !errors.Is(err, fs.ErrPermission) {
+		return false
+	}
+	res.errors++
+	res.denied++
+	c.errorf("Could not %s %s: permission denied on parent directory %s", what, name, filepath.Dir(name))
+	return true
+}
+
+// targetPath returns the target of the link path resolved against the
+// directory of the link, without following any links.
+func targetPath(path, target string) string {
+	if filepath.IsAbs(target) {
+		return filepath.Clean(target)
+	}
+	return filepath.Join(filepath.Dir(path), target)
+}
+
+// displayTarget returns the target of the link name as shown in messages.
+// A relative target is followed by the path it refers to, if it differs.
+func displayTarget(name, target string) string {
+	resolved := targetPath(name, target)
+	if filepath.IsAbs(target) || resolved == target {
+		return target
+	}
+	return fmt.Sprintf("%s (%s)", target, resolved)
+}
+
+// display returns the path of a link as shown in messages. With several
+// roots the path is prefixed with its root to make clear where it was found.
+// TrimPrefix is removed afterwards.
+func (c *Checker) display(root rootDir, path string) string {
+	name := c.displayPath(root, path)
+	if c.TrimPrefix == "" || !strings.HasPrefix(name, c.TrimPrefix) {
+		return name
+	}
+	if trimmed := strings.TrimPrefix(name[len(c.TrimPrefix):], string(filepath.Separator)); trimmed != "" {
+		return trimmed
+	}
+	return name
+}
+
+func (c *Checker) displayPath(root rootDir, path string) string {
+	switch {
+	case c.relativeTo != "":
+		abs := filepath.Join(root.abs, path)
+		if rel, err := filepath.Rel(c.relativeTo, abs); err == nil && within(c.relativeTo, abs) {
+			return rel
+		}
+		return abs
+	case c.Absolute:
+		return filepath.Join(root.abs, path)
+	case len(c.Roots) > 1 || root.file:
+		return filepath.Join(root.name, path)
+	}
+	return path
+}
@@ -0,0 +1,576 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// buildTree creates the entries of spec below a new temporary directory and
+// returns its path. Each entry is one of
+//
+//	dir  path
+//	file path
+//	link path -> target
+//
+// Parent directories are created as needed, targets are used verbatim.
+func buildTree(t testing.TB, spec ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, entry := range spec {
+		kind, arg, _ := strings.Cut(entry, " ")
+		if kind == "link" {
+			name, target, ok := strings.Cut(arg, " -> ")
+			if !ok {
+				t.Fatalf("invalid link entry %q", entry)
+			}
+			path := filepath.Join(root, filepath.FromSlash(name))
+			mkdirAll(t, filepath.Dir(path))
+			if err := os.Symlink(filepath.FromSlash(target), path); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		path := filepath.Join(root, filepath.FromSlash(arg))
+		switch kind {
+		case "dir":
+			mkdirAll(t, path)
+		case "file":
+			mkdirAll(t, filepath.Dir(path))
+			if err := os.WriteFile(path, []byte("content\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		default:
+			t.Fatalf("invalid entry %q", entry)
+		}
+	}
+	return root
+}
+
+// brokenLinks returns the spec of n broken links.
+func brokenLinks(n int) []string {
+	spec := make([]string, n)
+	for i := range spec {
+		spec[i] = fmt.Sprintf("link l%d -> gone", i)
+	}
+	return spec
+}
+
+func mkdirAll(t testing.TB, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// logTime matches the time stamp of log.LstdFlags.
+var logTime = regexp.MustCompile(`(?m)^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d `)
+
+// normalize removes the parts of the output which differ between runs.
+func normalize(s, root string) string {
+	s = strings.ReplaceAll(s, root, "$ROOT")
+	return logTime.ReplaceAllString(s, "")
+}
+
+// checkGolden compares got with testdata/name.golden, or writes the file
+// with the -update flag.
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestRunGolden(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need privileges and messages differ on Windows")
+	}
+	tests := []struct {
+		name    string
+		tree    []string
+		checker Checker
+		want    Stats
+		gone    []string // links removed by the run
+	}{
+		{
+			name: "healthy",
+			tree: []string{
+				"file f.txt",
+				"dir d",
+				"link file -> f.txt",
+				"link dir -> d",
+				"link d/up -> ../f.txt",
+			},
+			checker: Checker{Verbosity: LevelOK},
+			want:    Stats{LinksInspected: 3},
+		},
+		{
+			name: "broken",
+			tree: []string{
+				"file f.txt",
+				"link ok -> f.txt",
+				"link missing -> nothing",
+				"link sub/missing -> ../sub/nothing",
+			},
+			checker: Checker{},
+			want:    Stats{LinksInspected: 3, BrokenLinks: 2},
+		},
+		{
+			name: "circular",
+			tree: []string{
+				"link a -> b",
+				"link b -> a",
+				"link self -> self",
+				"link via -> a/x",
+			},
+			checker: Checker{},
+			want:    Stats{LinksInspected: 4, BrokenLinks: 3, CircularLinks: 2, SelfLinks: 1, CascadedLinks: 1},
+		},
+		{
+			name: "nested",
+			tree: []string{
+				"file a/b/c/f.txt",
+				"link a/l1 -> b/c/f.txt",
+				"link a/b/l2 -> c/f.txt",
+				"link a/b/c/l3 -> gone",
+				"link a/b/c/d/l4 -> ../f.txt",
+			},
+			checker: Checker{Verbosity: LevelDirs},
+			want:    Stats{LinksInspected: 4, BrokenLinks: 1},
+		},
+		{
+			name: "max-depth",
+			tree: []string{
+				"link top -> gone",
+				"link a/l -> gone",
+				"link a/b/l -> gone",
+			},
+			checker: Checker{MaxDepth: 1},
+			want:    Stats{LinksInspected: 1, BrokenLinks: 1},
+		},
+		{
+			name: "skip-hidden",
+			tree: []string{
+				"link .dot -> gone",
+				"link .cache/l -> gone",
+				"link visible/l -> gone",
+			},
+			checker: Checker{SkipHidden: true},
+			want:    Stats{LinksInspected: 1, BrokenLinks: 1},
+		},
+		{
+			name: "should-visit",
+			tree: []string{
+				"link vendor/l -> gone",
+				"link skip.me -> gone",
+				"link keep -> gone",
+			},
+			checker: Checker{
+				ShouldVisit: func(path string, d fs.DirEntry) bool {
+					return d.Name() != "vendor" && !strings.HasSuffix(path, ".me")
+				},
+			},
+			want: Stats{LinksInspected: 1, BrokenLinks: 1},
+		},
+		{
+			name: "delete-broken",
+			tree: []string{
+				"file f.txt",
+				"link ok -> f.txt",
+				"link missing -> nothing",
+				"link sub/missing -> nothing",
+			},
+			checker: Checker{DeleteBroken: true},
+			want:    Stats{LinksInspected: 3, BrokenLinks: 2, LinksRemoved: 2},
+			gone:    []string{"missing", "sub/missing"},
+		},
+		{
+			name: "json",
+			tree: []string{
+				"file f.txt",
+				"link ok -> f.txt",
+				"link missing -> nothing",
+			},
+			checker: Checker{Format: FormatJSON},
+			want:    Stats{LinksInspected: 2, BrokenLinks: 1},
+		},
+		{
+			name: "ndjson",
+			tree: []string{
+				"file f.txt",
+				"link ok -> f.txt",
+				"link missing -> nothing",
+			},
+			checker: Checker{Format: FormatNDJSON},
+			want:    Stats{LinksInspected: 2, BrokenLinks: 1},
+		},
+		{
+			name: "template",
+			tree: []string{
+				"file f.txt",
+				"link ok -> f.txt",
+				"link missing -> nothing",
+			},
+			checker: Checker{
+				Template: template.Must(template.New("t").Parse("{{if .Broken}}{{.Path}} {{.Reason}}{{end}}")),
+			},
+			want: Stats{LinksInspected: 2, BrokenLinks: 1},
+		},
+		{
+			name: "csv",
+			tree: []string{
+				"file f.txt",
+				"link ok -> f.txt",
+				"link missing -> nothing",
+			},
+			checker: Checker{Format: FormatCSV},
+			want:    Stats{LinksInspected: 2, BrokenLinks: 1},
+		},
+		{
+			name: "absolute-trim-prefix",
+			tree: []string{
+				"file out/f.txt",
+				"link out/ok -> f.txt",
+				"link out/l -> nothing",
+			},
+			checker: Checker{Absolute: true, TrimPrefix: "$ROOT", Format: FormatCSV},
+			want:    Stats{LinksInspected: 2, BrokenLinks: 1},
+		},
+		{
+			name: "cascade-filtered-ancestor",
+			tree: []string{
+				"link libdir -> /nonexistent",
+				"link libfoo.so -> libdir/libfoo.so.1",
+				"link sub/l -> ../libdir/x",
+			},
+			checker: Checker{Include: []string{"*.so"}, DeleteBroken: true},
+			want:    Stats{LinksInspected: 1, BrokenLinks: 1, LinksRemoved: 1},
+			gone:    []string{"libfoo.so"},
+		},
+		{
+			name: "junit",
+			tree: []string{
+				"file f.txt",
+				"link ok -> f.txt",
+				"link missing -> nothing",
+			},
+			checker: Checker{Format: FormatJUnit},
+			want:    Stats{LinksInspected: 2, BrokenLinks: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := buildTree(t, tt.tree...)
+			var stdout, stderr bytes.Buffer
+			c := tt.checker
+			c.Roots = []string{root}
+			if c.TrimPrefix == "$ROOT" {
+				c.TrimPrefix = root // like normalize
+			}
+			c.Sort = true
+			c.Stdout = &stdout
+			c.Stderr = &stderr
+			stats, err := c.Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the target lengths are covered by the json golden files
+			stats.TargetBytes, stats.MinTargetLength, stats.MaxTargetLength = 0, 0, 0
+			if stats != tt.want {
+				t.Errorf("stats: got %+v, want %+v", stats, tt.want)
+			}
+			for _, name := range tt.gone {
+				if _, err := os.Lstat(filepath.Join(root, name)); !os.IsNotExist(err) {
+					t.Errorf("link %s was not removed", name)
+				}
+			}
+			got := "# stdout\n" + stdout.String() + "# stderr\n" + stderr.String()
+			checkGolden(t, tt.name, normalize(got, root))
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need privileges on Windows")
+	}
+	tests := []struct {
+		name    string
+		tree    []string
+		roots   []string // directories of the tree to check, the whole tree if empty
+		checker Checker
+		want    Stats
+		err     error    // returned by Run
+		exist   []string // files which must exist after the run, $TREE is the path of the tree
+		gone    []string // files which must not exist after the run, likewise
+		targets []string // "link -> target" of links after the run
+		locked  []string // directories made unreadable before the run, skipped as root
+	}{
+		{
+			name: "touch-targets",
+			tree: []string{
+				"link root/l -> newdir/file",
+			},
+			roots:   []string{"root"},
+			checker: Checker{TouchTargets: true},
+			want:    Stats{LinksInspected: 1, BrokenLinks: 1, Placeholders: 1},
+			exist:   []string{"root/newdir/file"},
+		},
+		{
+			name: "touch-targets-through-linked-dir",
+			tree: []string{
+				"dir outside",
+				"link root/sub -> ../outside",
+				"link root/l -> sub/newdir/file",
+			},
+			roots:   []string{"root"},
+			checker: Checker{TouchTargets: true},
+			want:    Stats{LinksInspected: 2, BrokenLinks: 1},
+			gone:    []string{"outside/newdir"},
+		},
+		{
+			name: "quarantine-roots",
+			tree: []string{
+				"link a/x -> gone",
+				"link b/x -> gone",
+			},
+			roots:   []string{"a", "b"},
+			checker: Checker{Quarantine: "q"},
+			want:    Stats{LinksInspected: 2, BrokenLinks: 2, QuarantinedLinks: 2},
+			exist:   []string{"q/$TREE/a/x", "q/$TREE/b/x"},
+			gone:    []string{"a/x", "b/x", "q/$TREE/a/x.1", "q/$TREE/b/x.1"},
+		},
+		{
+			name:    "max-depth-unlimited",
+			tree:    []string{"link top -> gone", "link a/b/c/d/l -> gone"},
+			checker: Checker{},
+			want:    Stats{LinksInspected: 2, BrokenLinks: 2},
+		},
+		{
+			name:    "max-depth-root",
+			tree:    []string{"link top -> gone", "link a/l -> gone"},
+			checker: Checker{MaxDepth: -1},
+			want:    Stats{},
+		},
+		{
+			name: "resolve-full",
+			tree: []string{
+				"link a -> b",
+				"link b -> gone",
+				"link self -> self",
+			},
+			want: Stats{LinksInspected: 3, BrokenLinks: 3, SelfLinks: 1},
+		},
+		{
+			name: "resolve-target",
+			tree: []string{
+				"link a -> b",
+				"link b -> gone",
+				"link self -> self",
+			},
+			checker: Checker{ResolveMode: ResolveTarget},
+			want:    Stats{LinksInspected: 3, BrokenLinks: 2, SelfLinks: 1},
+		},
+		{
+			name: "resolve-target-cascaded",
+			tree: []string{
+				"dir d",
+				"link dir -> d",
+				"link gone -> nothing",
+				"link sub/l -> ../gone/x",
+			},
+			checker: Checker{ResolveMode: ResolveTarget},
+			want:    Stats{LinksInspected: 3, BrokenLinks: 1, CascadedLinks: 1},
+		},
+		{
+			name: "replace-target",
+			tree: []string{
+				"link l -> /old/x",
+				"link sub/m -> /old",
+				"link n -> /older/x",
+			},
+			checker: Checker{ReplaceFrom: "/old", ReplaceTo: "/new"},
+			want:    Stats{LinksInspected: 3, BrokenLinks: 3, RetargetedLinks: 2},
+			targets: []string{"l -> /new/x", "sub/m -> /new", "n -> /older/x"},
+		},
+		{
+			name: "replace-target-dry-run",
+			tree: []string{
+				"link l -> /old/x",
+			},
+			checker: Checker{ReplaceFrom: "/old/", ReplaceTo: "/new", DryRun: true},
+			want:    Stats{LinksInspected: 1, BrokenLinks: 1, RetargetedLinks: 1},
+			targets: []string{"l -> /old/x"},
+		},
+		{
+			name: "walk-error",
+			tree: []string{
+				"link a/locked/l -> gone",
+				"link b/l -> gone",
+			},
+			err:    fs.ErrPermission,
+			locked: []string{"a/locked"},
+		},
+		{
+			name: "continue-on-error",
+			tree: []string{
+				"link a/locked/l -> gone",
+				"link b/l -> gone",
+			},
+			checker: Checker{ContinueOnError: true},
+			want:    Stats{LinksInspected: 1, BrokenLinks: 1, Errors: 1, PermissionErrors: 1},
+			locked:  []string{"a/locked"},
+		},
+		{
+			name: "root-not-entered",
+			tree: []string{
+				"link a/l -> gone",
+				"link b/l -> gone",
+			},
+			roots:  []string{"a", "b"},
+			want:   Stats{LinksInspected: 1, BrokenLinks: 1, Errors: 1, PermissionErrors: 1},
+			locked: []string{"a"},
+		},
+		{
+			name: "expect-file",
+			tree: []string{
+				"file f",
+				"dir d",
+				"link file -> f",
+				"link dir -> d",
+			},
+			checker: Checker{ExpectFile: true},
+			want:    Stats{LinksInspected: 2, TypeMismatches: 1},
+		},
+		{
+			name: "expect-executable",
+			tree: []string{
+				"file f",
+				"link l -> f",
+			},
+			checker: Checker{ExpectExecutable: true},
+			want:    Stats{LinksInspected: 1, NonExecutable: 1},
+		},
+		{
+			name: "max-resolve-depth",
+			tree: []string{
+				"file f",
+				"link direct -> f",
+				"link chained -> direct",
+			},
+			checker: Checker{MaxResolveDepth: 1},
+			want:    Stats{LinksInspected: 2, OverDepthLinks: 1},
+		},
+		{
+			name: "flag-network",
+			tree: []string{
+				"link l -> /net/host/x",
+				"link m -> /netx",
+			},
+			checker: Checker{FlagNetwork: true, NetworkPrefixes: []string{"/net"}},
+			want:    Stats{LinksInspected: 2, BrokenLinks: 2, NetworkLinks: 1},
+		},
+		{
+			name: "dedup-inodes",
+			tree: []string{
+				"link d/l -> gone",
+			},
+			roots:   []string{".", "d"},
+			checker: Checker{DedupInodes: true},
+			want:    Stats{LinksInspected: 1, BrokenLinks: 1, DuplicateLinks: 1},
+		},
+		{
+			name:    "max-broken",
+			tree:    brokenLinks(30),
+			checker: Checker{MaxBroken: 3, Workers: 8},
+			want:    Stats{LinksInspected: 3, BrokenLinks: 3},
+			err:     ErrMaxBroken,
+		},
+		{
+			name:    "max-broken-sorted",
+			tree:    brokenLinks(30),
+			checker: Checker{MaxBroken: 3, Workers: 8, Sort: true},
+			want:    Stats{LinksInspected: 3, BrokenLinks: 3},
+			err:     ErrMaxBroken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := buildTree(t, tt.tree...)
+			c := tt.checker
+			c.Roots = []string{tree}
+			if tt.roots != nil {
+				c.Roots = nil
+				for _, root := range tt.roots {
+					c.Roots = append(c.Roots, filepath.Join(tree, filepath.FromSlash(root)))
+				}
+			}
+			if len(tt.locked) > 0 && os.Geteuid() == 0 {
+				t.Skip("permissions are not enforced for root")
+			}
+			for _, dir := range tt.locked {
+				dir = filepath.Join(tree, filepath.FromSlash(dir))
+				if err := os.Chmod(dir, 0); err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { os.Chmod(dir, 0o755) })
+			}
+			if c.Quarantine != "" {
+				c.Quarantine = filepath.Join(tree, c.Quarantine)
+			}
+			c.Stdout = io.Discard
+			c.Stderr = io.Discard
+			stats, err := c.Run(context.Background())
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			stats.TargetBytes, stats.MinTargetLength, stats.MaxTargetLength = 0, 0, 0
+			if stats != tt.want {
+				t.Errorf("stats: got %+v, want %+v", stats, tt.want)
+			}
+			for _, name := range tt.exist {
+				name = strings.ReplaceAll(name, "$TREE", tree)
+				if _, err := os.Lstat(filepath.Join(tree, filepath.FromSlash(name))); err != nil {
+					t.Errorf("%s does not exist: %v", name, err)
+				}
+			}
+			for _, name := range tt.gone {
+				name = strings.ReplaceAll(name, "$TREE", tree)
+				if _, err := os.Lstat(filepath.Join(tree, filepath.FromSlash(name))); !os.IsNotExist(err) {
+					t.Errorf("%s exists", name)
+				}
+			}
+			for _, entry := range tt.targets {
+				name, want, _ := strings.Cut(entry, " -> ")
+				got, err := os.Readlink(filepath.Join(tree, filepath.FromSlash(name)))
+				if err != nil || got != want {
+					t.Errorf("%s -> %s (%v), want %s", name, got, err, want)
+				}
+			}
+		})
+	}
+}
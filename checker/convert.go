@@ -0,0 +1,78 @@
+package checker
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// makeRelative rewrites the healthy link path as a relative link if its
+// target is absolute and lies below the root, as given or with its
+// symlinks resolved. Links pointing outside of the root are left alone.
+func (c *Checker) makeRelative(root rootDir, path, name, target string, res *result) {
+	if !filepath.IsAbs(target) {
+		return
+	}
+	if !within(root.abs, target) && !within(root.real, target) {
+		c.logf(LevelOK, "not converting %s: target %s is outside of the root", name, target)
+		return
+	}
+	// A relative target is resolved against the real directory of the
+	// link, so both directories are resolved. The last element of the
+	// target stays as it is, it may be a link itself.
+	dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	var targetDir, rel string
+	if err == nil {
+		targetDir, err = filepath.EvalSymlinks(filepath.Dir(target))
+	}
+	if err == nil {
+		rel, err = filepath.Rel(dir, filepath.Join(targetDir, filepath.Base(target)))
+	}
+	if err != nil {
+		res.errors++
+		c.errorf("Could not convert %s: %v", name, err)
+		return
+	}
+	c.convert(path, name, target, rel, res)
+}
+
+// makeAbsolute rewrites the healthy link path as an absolute link if its
+// target is relative. The target is resolved against the directory of the
+// link, which also works for targets escaping the root with "..".
+func (c *Checker) makeAbsolute(root rootDir, path, name, target string, res *result) {
+	if filepath.IsAbs(target) {
+		return
+	}
+	abs := filepath.Join(filepath.Dir(path), target)
+	if !within(root.abs, abs) {
+		c.logf(LevelOK, "target %s of %s is outside of the root", target, name)
+	}
+	c.convert(path, name, target, abs, res)
+}
+
+// convert replaces the link path to target by a link to newTarget. With
+// DryRun the link is left alone and the change is only shown.
+func (c *Checker) convert(path, name, target, newTarget string, res *result) {
+	if c.DryRun {
+		c.event(LevelBroken, "converted", fields{"path", name, "target", target, "new_target", newTarget, "dry_run", true},
+			"%s: %s => %s", name, target, newTarget)
+		res.link.Converted = true
+		return
+	}
+	c.logf(LevelBroken, "Convert link %s -> %s", name, newTarget)
+	if err := relink(path, newTarget); err != nil {
+		res.errors++
+		c.errorf("Could not convert %s: %v", name, err)
+		return
+	}
+	c.dirCache.invalidate()
+	res.link.Converted = true
+}
+
+// within reports whether the absolute path lies below or is equal to dir.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,44 @@
+package checker
+
+import (
+	"io/fs"
+	"sync"
+)
+
+// linkSet records the links seen by all walks of a run with DedupInodes.
+type linkSet struct {
+	mu   sync.Mutex
+	seen map[fileID]bool
+	dups int // links skipped because they were seen before
+}
+
+func newLinkSet() *linkSet {
+	return &linkSet{seen: make(map[fileID]bool)}
+}
+
+// firstVisit reports whether the link path was not reached before through
+// another path, e.g. a bind mount. Links whose identity is unknown count
+// as new.
+func (c *Checker) firstVisit(path, name string, d fs.DirEntry) bool {
+	if c.links == nil {
+		return true
+	}
+	fi, err := d.Info()
+	if err != nil {
+		return true
+	}
+	id, ok := getLinkID(path, fi)
+	if !ok {
+		return true
+	}
+	s := c.links
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen[id] {
+		s.dups++
+		c.logf(LevelOK, "skip duplicate link %s: same inode as a link seen before", name)
+		return false
+	}
+	s.seen[id] = true
+	return true
+}
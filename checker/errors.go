@@ -0,0 +1,27 @@
+package checker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMaxBroken is returned by Run together with the partial Stats if the
+// walk was stopped after Checker.MaxBroken broken links. The report is
+// still written.
+var ErrMaxBroken = errors.New("too many broken links")
+
+// WalkError is returned by Run if a path below a root could not be
+// accessed, e.g. a directory without read permission. With
+// ContinueOnError such errors are only logged and counted.
+type WalkError struct {
+	Path string // including the root directory, as shown in messages
+	Err  error
+}
+
+func (e *WalkError) Error() string {
+	return fmt.Sprintf("could not access %s: %v", e.Path, e.Err)
+}
+
+func (e *WalkError) Unwrap() error {
+	return e.Err
+}
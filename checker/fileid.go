@@ -0,0 +1,34 @@
+//go:build !windows
+
+package checker
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileID identifies a file by device and inode.
+type fileID struct {
+	dev, ino uint64
+}
+
+// getFileID returns the identity of the file path described by fi.
+func getFileID(path string, fi fs.FileInfo) (fileID, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
+
+// getDevice returns the device the file described by fi is stored on.
+func getDevice(path string, fi fs.FileInfo) (uint64, bool) {
+	id, ok := getFileID(path, fi)
+	return id.dev, ok
+}
+
+// getLinkID returns the identity of the symlink path itself described by
+// the Lstat result fi.
+func getLinkID(path string, fi fs.FileInfo) (fileID, bool) {
+	return getFileID(path, fi)
+}
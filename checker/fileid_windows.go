@@ -0,0 +1,36 @@
+package checker
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// fileID identifies a file by its absolute path with all symlinks
+// resolved, Windows provides no inode numbers in fs.FileInfo.
+type fileID struct {
+	path string
+}
+
+// getFileID returns the identity of the file path described by fi.
+func getFileID(path string, fi fs.FileInfo) (fileID, bool) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fileID{}, false
+	}
+	abs, err := filepath.Abs(resolved)
+	if err != nil {
+		return fileID{}, false
+	}
+	return fileID{path: abs}, true
+}
+
+// getDevice is not supported on Windows, OneFileSystem has no effect.
+func getDevice(path string, fi fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+// getLinkID is not supported on Windows, getFileID identifies the target
+// of a link. DedupInodes has no effect.
+func getLinkID(path string, fi fs.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
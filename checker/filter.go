@@ -0,0 +1,117 @@
+package checker
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// excluded reports whether path, relative to the root, matches one of the
+// exclude patterns or is hidden with SkipHidden.
+func (c *Checker) excluded(path string) bool {
+	if c.SkipHidden && strings.HasPrefix(filepath.Base(path), ".") {
+		return true
+	}
+	for _, pattern := range c.Exclude {
+		if matchGlob(pattern, path) {
+			return true
+		}
+	}
+	for _, re := range c.ExcludeRegexp {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// reportsBroken reports whether the walk inspects the broken link rel
+// below root and reports it as broken: neither the link nor one of its
+// parent directories is skipped by the filters, MaxDepth or Since, and the
+// link is not allowed to be broken. Ignore files are read anew, the state
+// of the walk cannot be shared with the workers.
+func (c *Checker) reportsBroken(root rootDir, rel string) bool {
+	if max := c.maxDepth(); root.file || max >= 0 && depth(rel) > max {
+		return false
+	}
+	ign := newIgnores(root.abs, c.IgnoreFile)
+	dir := "."
+	elems := strings.Split(rel, string(filepath.Separator))
+	for i, elem := range elems {
+		if err := ign.enter(dir); err != nil {
+			return false
+		}
+		entry := filepath.Join(dir, elem)
+		isDir := i < len(elems)-1
+		if c.excluded(entry) || ign.ignored(entry, isDir) {
+			return false
+		}
+		path := filepath.Join(root.abs, entry)
+		if c.quarantineDir != "" && path == c.quarantineDir {
+			return false
+		}
+		fi, err := os.Lstat(path)
+		if err != nil {
+			return false
+		}
+		if c.ShouldVisit != nil && !c.ShouldVisit(path, fs.FileInfoToDirEntry(fi)) {
+			return false
+		}
+		if !isDir {
+			return c.included(rel) && c.modifiedSince(fs.FileInfoToDirEntry(fi)) && !c.allowedBroken(root, rel)
+		}
+		dir = entry
+	}
+	return false
+}
+
+// included reports whether the symlink path, relative to the root, matches
+// one of the include patterns. Without include patterns all symlinks are included.
+func (c *Checker) included(path string) bool {
+	if len(c.Include) == 0 {
+		return true
+	}
+	for _, pattern := range c.Include {
+		if matchGlob(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedBroken reports whether the broken link rel below root matches one
+// of the AllowBroken patterns. Absolute patterns match the absolute path.
+func (c *Checker) allowedBroken(root rootDir, rel string) bool {
+	for _, pattern := range c.AllowBroken {
+		if filepath.IsAbs(pattern) {
+			if ok, _ := filepath.Match(pattern, filepath.Join(root.abs, rel)); ok {
+				return true
+			}
+		} else if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob reports whether the shell pattern matches path or its base name,
+// so that a pattern like "node_modules" matches at any depth.
+func matchGlob(pattern, path string) bool {
+	if ok, _ := filepath.Match(pattern, path); ok {
+		return true
+	}
+	ok, _ := filepath.Match(pattern, filepath.Base(path))
+	return ok
+}
+
+// validateGlobs returns an error for the first malformed pattern.
+func validateGlobs(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package checker
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileIndex maps base names to the absolute paths of the regular files
+// below a directory.
+type fileIndex map[string][]string
+
+// buildFileIndex indexes all regular files below dir.
+func buildFileIndex(dir string) (fileIndex, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	idx := make(fileIndex)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			idx[d.Name()] = append(idx[d.Name()], path)
+		}
+		return nil
+	})
+	return idx, err
+}
+
+// fix retargets the broken link path to the single file in the FixTo
+// directory which has the same base name as the link target. It reports
+// whether the link was fixed.
+func (c *Checker) fix(path, name, target string, res *result) bool {
+	candidates := c.fixIndex[filepath.Base(target)]
+	switch len(candidates) {
+	case 0:
+		c.logf(LevelBroken, "Could not fix %s: no file named %q in %s", name, filepath.Base(target), c.FixTo)
+		return false
+	case 1:
+	default:
+		c.logf(LevelBroken, "Could not fix %s: %d files named %q in %s: %s", name, len(candidates),
+			filepath.Base(target), c.FixTo, strings.Join(candidates, ", "))
+		return false
+	}
+
+	newTarget := candidates[0]
+	if c.DryRun {
+		c.logf(LevelBroken, "would fix %s -> %s", name, newTarget)
+		res.link.Fixed = true
+		return true
+	}
+	c.logf(LevelBroken, "Fix broken link %s -> %s", name, newTarget)
+	if err := relink(path, newTarget); err != nil {
+		res.errors++
+		c.errorf("Could not fix %s: %v", name, err)
+		return false
+	}
+	c.dirCache.invalidate()
+	res.link.Fixed = true
+	return true
+}
+
+// relink replaces the symlink path by a symlink pointing to target. The new
+// link is created next to the old one and renamed over it, so that path is
+// never missing.
+func relink(path, target string) error {
+	tmp := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.checksymlinks%d", filepath.Base(path), os.Getpid()))
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package checker
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// enterDir records the directory path as visited and reports whether it
+// was not visited before. A directory reached a second time through a
+// symlink is skipped, which prevents infinite loops with Follow.
+func (c *Checker) enterDir(path, name string, d fs.DirEntry, visited map[fileID]bool) bool {
+	fi, err := d.Info()
+	if err != nil {
+		return true
+	}
+	id, ok := getFileID(path, fi)
+	if !ok {
+		return true
+	}
+	if visited[id] {
+		c.errorf("skip %s: directory already visited, possible symlink loop", name)
+		return false
+	}
+	visited[id] = true
+	return true
+}
+
+// onDevice reports whether the directory path is stored on the device dev.
+// Directories whose device cannot be determined are treated as on dev.
+func (c *Checker) onDevice(path string, d fs.DirEntry, dev uint64) bool {
+	fi, err := d.Info()
+	if err != nil {
+		return true
+	}
+	got, ok := getDevice(path, fi)
+	return !ok || got == dev
+}
+
+// follow walks the directory the symlink path points to with walk. A
+// trailing separator makes the walk descend into the target instead of
+// reporting the link itself.
+func follow(path string, walk func(root string) error) error {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.IsDir() {
+		return nil
+	}
+	return walk(path + string(filepath.Separator))
+}
@@ -0,0 +1,158 @@
+package checker
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a single gitignore-style pattern.
+type ignoreRule struct {
+	segments []string // pattern split at "/"
+	anchored bool     // match relative to the directory of the ignore file instead of the base name
+	negate   bool     // re-include a path excluded by a previous rule
+	dirOnly  bool     // match directories only
+}
+
+// parseIgnoreRule parses a line of an ignore file. It returns false for
+// blank lines and comments.
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	var r ignoreRule
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return r, false
+	}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:] // escaped leading "#" or "!"
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return r, false
+	}
+	r.segments = strings.Split(line, "/")
+	return r, true
+}
+
+// match reports whether the slash separated path rel, relative to the
+// directory of the ignore file, matches the rule.
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	if !r.anchored {
+		parts = parts[len(parts)-1:]
+	}
+	return matchSegments(r.segments, parts)
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches any number of segments.
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], parts[0])
+	return ok && matchSegments(pattern[1:], parts[1:])
+}
+
+// ignoreList holds the rules of the ignore file in dir. Rules of the
+// parent directories apply as well, the rules further down take precedence.
+type ignoreList struct {
+	parent *ignoreList
+	dir    string // relative to the root
+	rules  []ignoreRule
+}
+
+// ignored reports whether path, relative to the root, is ignored.
+func (l *ignoreList) ignored(path string, isDir bool) bool {
+	if l == nil {
+		return false
+	}
+	ignored := l.parent.ignored(path, isDir)
+	rel := filepath.ToSlash(path)
+	if l.dir != "." {
+		rel = strings.TrimPrefix(rel, filepath.ToSlash(l.dir)+"/")
+	}
+	for _, r := range l.rules {
+		if r.match(rel, isDir) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// ignores tracks the ignore files found during a walk.
+type ignores struct {
+	root  string                 // absolute path of the root directory
+	name  string                 // file name of ignore files, empty disables them
+	lists map[string]*ignoreList // rules in effect for the entries of a directory
+}
+
+func newIgnores(root, name string) *ignores {
+	return &ignores{root: root, name: name, lists: make(map[string]*ignoreList)}
+}
+
+// ignored reports whether the entry at path is ignored by the files in
+// its parent directories.
+func (ig *ignores) ignored(path string, isDir bool) bool {
+	return ig.lists[filepath.Dir(path)].ignored(path, isDir)
+}
+
+// enter reads the ignore file of the directory dir relative to the root,
+// if there is one.
+func (ig *ignores) enter(dir string) error {
+	if ig.name == "" {
+		return nil
+	}
+	var parent *ignoreList
+	if dir != "." {
+		parent = ig.lists[filepath.Dir(dir)]
+	}
+	ig.lists[dir] = parent
+
+	data, err := os.ReadFile(filepath.Join(ig.root, dir, ig.name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	l := &ignoreList{parent: parent, dir: dir}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if r, ok := parseIgnoreRule(scanner.Text()); ok {
+			l.rules = append(l.rules, r)
+		}
+	}
+	if len(l.rules) > 0 {
+		ig.lists[dir] = l
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package checker
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// junitReporter collects the links and writes a JUnit XML test suite with a
+// test case per link. Broken links are failures, links which timed out
+// errors and cascaded links are skipped.
+type junitReporter struct {
+	w     io.Writer
+	cases []junitCase
+}
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string       `xml:"name,attr"`
+	Classname string       `xml:"classname,attr"`
+	Failure   *junitResult `xml:"failure,omitempty"`
+	Error     *junitResult `xml:"error,omitempty"`
+	Skipped   *junitResult `xml:"skipped,omitempty"`
+}
+
+type junitResult struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+}
+
+func (r *junitReporter) add(link Link) {
+	tc := junitCase{Name: link.Path, Classname: link.Root}
+	switch {
+	case link.Broken:
+		tc.Failure = &junitResult{Message: fmt.Sprintf("%s -> %s: %s", link.Path, link.Target, link.Error), Type: link.Reason}
+	case link.TimedOut:
+		tc.Error = &junitResult{Message: fmt.Sprintf("%s -> %s: timed out", link.Path, link.Target), Type: "timeout"}
+	case link.Cascaded:
+		tc.Skipped = &junitResult{Message: "target lies below a broken link"}
+	}
+	r.cases = append(r.cases, tc)
+}
+
+// finish writes the suite. The counters are those of the test cases
+// written, e.g. only the broken links with Checker.OnlyBroken.
+func (r *junitReporter) finish(Stats, []RootStats) error {
+	suite := junitSuite{Name: "checksymlinks", Tests: len(r.cases), Cases: r.cases}
+	for _, tc := range r.cases {
+		switch {
+		case tc.Failure != nil:
+			suite.Failures++
+		case tc.Error != nil:
+			suite.Errors++
+		case tc.Skipped != nil:
+			suite.Skipped++
+		}
+	}
+	if _, err := io.WriteString(r.w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(r.w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(r.w, "\n")
+	return err
+}
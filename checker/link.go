@@ -0,0 +1,20 @@
+//go:build !windows
+
+package checker
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+// isLink reports whether the entry at path is a symbolic link.
+func isLink(path string, d fs.DirEntry) bool {
+	return d.Type()&fs.ModeSymlink != 0
+}
+
+// crossDevice reports whether err was returned by os.Rename because the
+// destination is on another file system.
+func crossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
@@ -0,0 +1,33 @@
+package checker
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// isLink reports whether the entry at path is a symbolic link or a
+// directory junction. Depending on the Go version and the winsymlink
+// GODEBUG setting, junctions and other reparse points are reported as
+// ModeIrregular instead of ModeSymlink, those count as links if they can
+// be read like one.
+func isLink(path string, d fs.DirEntry) bool {
+	if d.Type()&fs.ModeSymlink != 0 {
+		return true
+	}
+	if d.Type()&fs.ModeIrregular == 0 {
+		return false
+	}
+	_, err := os.Readlink(path)
+	return err == nil
+}
+
+// errorNotSameDevice is ERROR_NOT_SAME_DEVICE, which syscall does not define.
+const errorNotSameDevice = syscall.Errno(17)
+
+// crossDevice reports whether err was returned by os.Rename because the
+// destination is on another volume.
+func crossDevice(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
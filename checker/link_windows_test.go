@@ -0,0 +1,61 @@
+package checker
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// symlinkOrSkip creates the link or skips the test if the process lacks
+// the privilege to create symbolic links, see Developer Mode.
+func symlinkOrSkip(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+}
+
+func TestWindowsLinks(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "f.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	symlinkOrSkip(t, "f.txt", filepath.Join(root, "file-link"))
+	symlinkOrSkip(t, "dir", filepath.Join(root, "dir-link"))
+	symlinkOrSkip(t, "missing.txt", filepath.Join(root, "broken-link"))
+	// junctions need no privilege, but an absolute target
+	junction := filepath.Join(root, "junction")
+	if out, err := exec.Command("cmd", "/c", "mklink", "/J", junction, filepath.Join(root, "dir")).CombinedOutput(); err != nil {
+		t.Fatalf("mklink /J: %v: %s", err, out)
+	}
+	brokenJunction := filepath.Join(root, "broken-junction")
+	if out, err := exec.Command("cmd", "/c", "mklink", "/J", brokenJunction, filepath.Join(root, "gone")).CombinedOutput(); err != nil {
+		t.Fatalf("mklink /J: %v: %s", err, out)
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range entries {
+		want := d.Name() != "f.txt" && d.Name() != "dir"
+		if got := isLink(filepath.Join(root, d.Name()), d); got != want {
+			t.Errorf("isLink(%s) = %v, want %v", d.Name(), got, want)
+		}
+	}
+
+	c := Checker{Roots: []string{root}, Stdout: io.Discard, Stderr: io.Discard}
+	stats, err := c.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.LinksInspected != 5 || stats.BrokenLinks != 2 {
+		t.Errorf("got %d links and %d broken, want 5 and 2", stats.LinksInspected, stats.BrokenLinks)
+	}
+}
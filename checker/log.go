@@ -0,0 +1,118 @@
+package checker
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Verbosity levels, each level includes the messages of the levels below.
+const (
+	LevelBroken = 0 // broken links, modifications and errors
+	LevelOK     = 1 // healthy links
+	LevelDirs   = 2 // visited directories
+)
+
+// Log formats
+const (
+	LogFormatText   = "text"   // human readable messages
+	LogFormatLogfmt = "logfmt" // key=value lines, see Logfmt
+)
+
+// ANSI escape sequences of the events colored with Checker.Color.
+var eventColors = map[string]string{
+	"broken":  "\x1b[31m", // red
+	"removed": "\x1b[33m", // yellow
+}
+
+const colorReset = "\x1b[0m"
+
+// syncWriter serializes the writes to w.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// fields are the alternating keys and values of a logfmt event.
+type fields []interface{}
+
+// logf logs a message if the verbosity is at least level.
+func (c *Checker) logf(level int, format string, args ...interface{}) {
+	if c.CountOnly || c.Verbosity < level {
+		return
+	}
+	if c.LogFormat == LogFormatLogfmt {
+		c.logger.Print(Logfmt(levelName(level), "message", "msg", fmt.Sprintf(format, args...)))
+		return
+	}
+	c.logger.Printf(format, args...)
+}
+
+// event logs a message about a single link if the verbosity is at least
+// level. With LogFormatLogfmt the event and the key value pairs kv are
+// logged instead of the message.
+func (c *Checker) event(level int, event string, kv fields, format string, args ...interface{}) {
+	if c.CountOnly || c.Verbosity < level {
+		return
+	}
+	if c.LogFormat == LogFormatLogfmt {
+		c.logger.Print(Logfmt(levelName(level), event, kv...))
+		return
+	}
+	if color, ok := eventColors[event]; ok && c.Color {
+		c.logger.Print(color + fmt.Sprintf(format, args...) + colorReset)
+		return
+	}
+	c.logger.Printf(format, args...)
+}
+
+// errorf logs an error, regardless of the verbosity.
+func (c *Checker) errorf(format string, args ...interface{}) {
+	if c.LogFormat == LogFormatLogfmt {
+		c.logger.Print(Logfmt("error", "error", "msg", fmt.Sprintf(format, args...)))
+		return
+	}
+	c.logger.Printf(format, args...)
+}
+
+func levelName(level int) string {
+	if level >= LevelDirs {
+		return "debug"
+	}
+	return "info"
+}
+
+// Logfmt formats an event as a single line of key=value pairs, starting
+// with the current time, the level and the event. Values containing
+// spaces, quotes or equal signs are quoted. The timestamp of the standard
+// logger should be disabled with log.SetFlags(0) when logging these lines.
+func Logfmt(level, event string, kv ...interface{}) string {
+	var b strings.Builder
+	b.WriteString("time=" + time.Now().Format(time.RFC3339))
+	b.WriteString(" level=" + level)
+	b.WriteString(" event=" + event)
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&b, " %v=%s", kv[i], logfmtValue(kv[i+1]))
+	}
+	return b.String()
+}
+
+func logfmtValue(v interface{}) string {
+	s := ""
+	if v != nil {
+		s = fmt.Sprint(v)
+	}
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,151 @@
+package checker
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// ManifestEntry is a line of a manifest, it records a link and its target.
+type ManifestEntry struct {
+	Path   string `json:"path"`   // including the root directory, see Link.FullPath
+	Target string `json:"target"` // raw contents of the link
+}
+
+// manifestHash is the last line of a manifest.
+type manifestHash struct {
+	SHA256 string `json:"sha256"` // of all lines before
+}
+
+// Manifest returns the links found by the last run with RecordManifest,
+// sorted by path. Removed links are left out. It must not be called
+// during a run.
+func (c *Checker) Manifest() []ManifestEntry {
+	entries := append([]ManifestEntry(nil), c.manifest...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries
+}
+
+// record adds the link to the manifest as it is after the run.
+func (c *Checker) record(link Link) {
+	if c.DryRun {
+		c.manifest = append(c.manifest, ManifestEntry{Path: link.FullPath(), Target: link.Target})
+		return
+	}
+	if link.Removed || link.Quarantined {
+		return
+	}
+	target := link.Target
+	if link.Fixed || link.Converted || link.Retargeted {
+		if t, err := os.Readlink(link.FullPath()); err == nil {
+			target = t
+		}
+	}
+	c.manifest = append(c.manifest, ManifestEntry{Path: link.FullPath(), Target: target})
+}
+
+// WriteManifest writes the entries as JSON lines in the given order,
+// followed by a line with the SHA-256 hash of these lines. The same links
+// always produce the same manifest. It returns the hash.
+func WriteManifest(w io.Writer, entries []ManifestEntry) (string, error) {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return "", err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	hash := hex.EncodeToString(sum[:])
+	data, err := json.Marshal(manifestHash{hash})
+	if err != nil {
+		return "", err
+	}
+	buf.Write(data)
+	buf.WriteByte('\n')
+	_, err = w.Write(buf.Bytes())
+	return hash, err
+}
+
+// ReadManifest returns the entries of the manifest r, written by
+// WriteManifest, after verifying its hash.
+func ReadManifest(r io.Reader) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	h := sha256.New()
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		var hash manifestHash
+		if err := json.Unmarshal(scanner.Bytes(), &hash); err == nil && hash.SHA256 != "" {
+			if got := hex.EncodeToString(h.Sum(nil)); got != hash.SHA256 {
+				return nil, fmt.Errorf("line %d: hash mismatch, the manifest was modified", line)
+			}
+			return entries, nil
+		}
+		var e ManifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+		h.Write(scanner.Bytes())
+		h.Write([]byte{'\n'})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("no hash, the manifest is incomplete")
+}
+
+// ManifestDiff lists the differences between two manifests.
+type ManifestDiff struct {
+	Added      []ManifestEntry // links only in the new manifest
+	Removed    []ManifestEntry // links only in the old manifest
+	Retargeted []Retarget      // links with another target in the new manifest
+}
+
+// Retarget is a link whose target changed.
+type Retarget struct {
+	Path      string
+	OldTarget string
+	NewTarget string
+}
+
+// Len returns the number of differences.
+func (d ManifestDiff) Len() int {
+	return len(d.Added) + len(d.Removed) + len(d.Retargeted)
+}
+
+// CompareManifests returns the differences between the entries of an old
+// and a new manifest, each list sorted by path.
+func CompareManifests(old, new []ManifestEntry) ManifestDiff {
+	var d ManifestDiff
+	targets := make(map[string]string, len(old))
+	for _, e := range old {
+		targets[e.Path] = e.Target
+	}
+	for _, e := range new {
+		target, ok := targets[e.Path]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, e)
+		case target != e.Target:
+			d.Retargeted = append(d.Retargeted, Retarget{Path: e.Path, OldTarget: target, NewTarget: e.Target})
+		}
+		delete(targets, e.Path)
+	}
+	for path, target := range targets {
+		d.Removed = append(d.Removed, ManifestEntry{Path: path, Target: target})
+	}
+	sort.Slice(d.Added, func(i, j int) bool { return d.Added[i].Path < d.Added[j].Path })
+	sort.Slice(d.Removed, func(i, j int) bool { return d.Removed[i].Path < d.Removed[j].Path })
+	sort.Slice(d.Retargeted, func(i, j int) bool { return d.Retargeted[i].Path < d.Retargeted[j].Path })
+	return d
+}
@@ -0,0 +1,50 @@
+package checker
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	entries := []ManifestEntry{{"a/l1", "../f"}, {"a/l2", "tab\there"}, {"b/l3", "/abs"}}
+	var buf bytes.Buffer
+	hash, err := WriteManifest(&buf, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again bytes.Buffer
+	if hash2, _ := WriteManifest(&again, entries); hash2 != hash || again.String() != buf.String() {
+		t.Errorf("manifest is not reproducible")
+	}
+	got, err := ReadManifest(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Errorf("got %v, want %v", got, entries)
+	}
+
+	modified := strings.Replace(buf.String(), "../f", "../g", 1)
+	if _, err := ReadManifest(strings.NewReader(modified)); err == nil {
+		t.Error("modified manifest was accepted")
+	}
+	truncated := buf.String()[:strings.LastIndex(buf.String(), `{"sha256"`)]
+	if _, err := ReadManifest(strings.NewReader(truncated)); err == nil {
+		t.Error("manifest without hash was accepted")
+	}
+}
+
+func TestCompareManifests(t *testing.T) {
+	old := []ManifestEntry{{"kept", "t"}, {"gone", "t"}, {"moved", "old"}}
+	new := []ManifestEntry{{"kept", "t"}, {"moved", "new"}, {"added", "t"}}
+	want := ManifestDiff{
+		Added:      []ManifestEntry{{"added", "t"}},
+		Removed:    []ManifestEntry{{"gone", "t"}},
+		Retargeted: []Retarget{{"moved", "old", "new"}},
+	}
+	if got := CompareManifests(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,40 @@
+package checker
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// reportNetwork flags the link if its absolute target lies on the network,
+// see isNetworkPath. A link is flagged at most once.
+func (c *Checker) reportNetwork(name, target string, res *result) {
+	if res.link.NetworkTarget || !c.isNetworkPath(target) {
+		return
+	}
+	c.event(LevelBroken, "network_target", fields{"path", name, "target", target},
+		"link %s points to the network path %s", name, target)
+	res.link.NetworkTarget = true
+}
+
+// isNetworkPath reports whether the absolute path lies below one of the
+// NetworkPrefixes or, on Windows, is a UNC path like \\server\share\f.
+func (c *Checker) isNetworkPath(path string) bool {
+	if runtime.GOOS == "windows" {
+		vol := filepath.VolumeName(path)
+		switch {
+		case strings.HasPrefix(vol, `\\?\UNC\`):
+			return true
+		case strings.HasPrefix(vol, `\\?\`), strings.HasPrefix(vol, `\\.\`):
+			// local device paths
+		case strings.HasPrefix(vol, `\\`):
+			return true
+		}
+	}
+	for _, prefix := range c.NetworkPrefixes {
+		if within(filepath.Clean(prefix), path) {
+			return true
+		}
+	}
+	return false
+}
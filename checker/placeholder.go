@@ -0,0 +1,66 @@
+package checker
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// touchTarget creates an empty file at the missing target of the broken
+// link path, so that the link resolves. Only relative targets below the
+// root are created, together with their parent directories. The deepest
+// existing ancestor of the target is resolved first, so that nothing is
+// created outside of the root through a linked directory.
+func (c *Checker) touchTarget(root rootDir, path, name, target string, res *result) {
+	if filepath.IsAbs(target) {
+		c.logf(LevelBroken, "Not creating placeholder for %s: target %s is absolute", name, target)
+		return
+	}
+	dst := filepath.Join(filepath.Dir(path), target)
+	if !within(root.abs, dst) || dst == root.abs {
+		c.logf(LevelBroken, "Not creating placeholder for %s: target %s is outside of the root", name, target)
+		return
+	}
+	if real, err := filepath.EvalSymlinks(existingAncestor(dst)); err != nil || !within(root.real, real) {
+		c.logf(LevelBroken, "Not creating placeholder for %s: target %s is outside of the root", name, target)
+		return
+	}
+	if c.DryRun {
+		c.logf(LevelBroken, "would create placeholder %s for %s", dst, name)
+		res.link.Placeholder = true
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		res.errors++
+		c.errorf("Could not create placeholder for %s: %v", name, err)
+		return
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		res.errors++
+		c.errorf("Could not create placeholder for %s: %v", name, err)
+		return
+	}
+	if err := f.Close(); err != nil {
+		res.errors++
+		c.errorf("Could not create placeholder for %s: %v", name, err)
+		return
+	}
+	c.logf(LevelBroken, "Created placeholder %s for %s", dst, name)
+	res.link.Placeholder = true
+}
+
+// existingAncestor returns the deepest parent directory of path which
+// exists.
+func existingAncestor(path string) string {
+	dir := filepath.Dir(path)
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
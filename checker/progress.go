@@ -0,0 +1,77 @@
+package checker
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progress counts the work done so far. The counters are updated and read
+// from different goroutines and must be accessed atomically.
+type progress struct {
+	scanned   int64 // entries visited by the walk
+	inspected int64
+	broken    int64
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// start prints a status line to w at every interval until stop is called.
+// If w is a terminal the line is overwritten, otherwise one line is printed each time.
+func (p *progress) start(w io.Writer, tty bool, interval time.Duration) {
+	p.stop = make(chan struct{})
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.print(w, tty)
+			case <-p.stop:
+				if tty {
+					p.print(w, tty)
+					fmt.Fprintln(w)
+				}
+				return
+			}
+		}
+	}()
+}
+
+func (p *progress) print(w io.Writer, tty bool) {
+	line := fmt.Sprintf("scanned: %d  inspected links: %d  broken links: %d",
+		atomic.LoadInt64(&p.scanned), atomic.LoadInt64(&p.inspected), atomic.LoadInt64(&p.broken))
+	if tty {
+		fmt.Fprintf(w, "\r%s", line)
+	} else {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// finish stops the status line, if it was started.
+func (p *progress) finish() {
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	p.wg.Wait()
+}
+
+// isTerminal reports whether w is a character device like a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
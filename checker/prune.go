@@ -0,0 +1,109 @@
+package checker
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// pruner collects the directories which may be empty after a run.
+type pruner struct {
+	mu   sync.Mutex
+	dirs map[string]string // absolute path to the path as shown in messages
+	gone map[string]bool   // absolute paths of the entries removed, to judge emptiness with DryRun
+}
+
+func newPruner() *pruner {
+	return &pruner{dirs: make(map[string]string), gone: make(map[string]bool)}
+}
+
+// add records the directory path as a candidate for pruning.
+func (p *pruner) add(path, name string) {
+	p.mu.Lock()
+	p.dirs[path] = name
+	p.mu.Unlock()
+}
+
+// removed records that the entry path was removed from its directory,
+// which becomes a candidate.
+func (p *pruner) removed(path, name string) {
+	p.mu.Lock()
+	p.gone[path] = true
+	p.dirs[filepath.Dir(path)] = filepath.Dir(name)
+	p.mu.Unlock()
+}
+
+// prune removes the empty candidate directories bottom-up, so that a
+// parent left empty by pruning its last subdirectory is pruned as well.
+// Roots and directories outside of them are never removed. It returns the
+// number of directories pruned and the number of errors.
+func (c *Checker) prune(roots []rootDir) (pruned, errs int) {
+	p := c.pruner
+	prunable := func(dir string) bool {
+		inside := false
+		for _, root := range roots {
+			if dir == root.abs {
+				return false
+			}
+			inside = inside || within(root.abs, dir)
+		}
+		return inside
+	}
+	byDepth := make(map[int][]string)
+	maxDepth := 0
+	push := func(dir string) {
+		d := strings.Count(dir, string(filepath.Separator))
+		byDepth[d] = append(byDepth[d], dir)
+		if d > maxDepth {
+			maxDepth = d
+		}
+	}
+	for dir := range p.dirs {
+		push(dir)
+	}
+	for d := maxDepth; d >= 0; d-- {
+		dirs := byDepth[d]
+		sort.Strings(dirs)
+		for i, dir := range dirs {
+			if i > 0 && dir == dirs[i-1] || !prunable(dir) || !p.empty(dir) {
+				continue
+			}
+			name := p.dirs[dir]
+			if c.DryRun {
+				c.logf(LevelBroken, "would prune empty dir %s", name)
+			} else {
+				if err := os.Remove(dir); err != nil {
+					errs++
+					c.errorf("Could not prune %s: %v", name, err)
+					continue
+				}
+				c.logf(LevelBroken, "Pruned empty dir %s", name)
+			}
+			pruned++
+			p.gone[dir] = true
+			parent := filepath.Dir(dir)
+			if _, ok := p.dirs[parent]; !ok {
+				p.dirs[parent] = filepath.Dir(name)
+			}
+			push(parent)
+		}
+	}
+	return pruned, errs
+}
+
+// empty reports whether the directory dir contains no entries besides
+// those removed.
+func (p *pruner) empty(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		if !p.gone[filepath.Join(dir, e.Name())] {
+			return false
+		}
+	}
+	return true
+}
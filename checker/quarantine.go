@@ -0,0 +1,83 @@
+package checker
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// quarantine moves the broken link path into the Quarantine directory, below
+// the absolute path of its root and keeping its path rel relative to the
+// root, so that links from several roots do not collide. If a link with the
+// same name already exists there, a numeric suffix is appended. The link is
+// renamed, keeping its owner and modification time, and only copied across
+// file systems.
+func (c *Checker) quarantine(root rootDir, path, rel, name, target string, res *result) {
+	dst := filepath.Join(c.quarantineDir, rootComponent(root.abs), rel)
+	if c.DryRun {
+		c.logf(LevelBroken, "would quarantine %s to %s", name, dst)
+		res.link.Quarantined = true
+		return
+	}
+	if _, err := os.Lstat(filepath.Dir(path)); c.permissionDenied("quarantine", name, err, res) {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		res.errors++
+		c.errorf("Could not quarantine %s: %v", name, err)
+		return
+	}
+	// Creating the new link fails if the name is taken, which makes
+	// the choice of a free name safe with concurrent workers. The link is
+	// then renamed over it.
+	candidate := dst
+	for i := 1; ; i++ {
+		err := os.Symlink(target, candidate)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			res.errors++
+			c.errorf("Could not quarantine %s: %v", name, err)
+			return
+		}
+		candidate = fmt.Sprintf("%s.%d", dst, i)
+	}
+	err := os.Rename(path, candidate)
+	if err == nil {
+		c.logf(LevelBroken, "Quarantined broken link %s to %s", name, candidate)
+		res.link.Quarantined = true
+		return
+	}
+	if !crossDevice(err) {
+		if !c.permissionDenied("quarantine", name, err, res) {
+			res.errors++
+			c.errorf("Could not quarantine %s: %v", name, err)
+		}
+		os.Remove(candidate)
+		return
+	}
+	// on another file system, keep the copy and remove the original
+	if err := c.retry("remove", name, func() error { return os.Remove(path) }); err != nil {
+		if !c.permissionDenied("quarantine", name, err, res) {
+			res.errors++
+			c.errorf("Could not remove quarantined link %s: %v", name, err)
+		}
+		os.Remove(candidate)
+		return
+	}
+	c.logf(LevelBroken, "Quarantined broken link %s to %s", name, candidate)
+	res.link.Quarantined = true
+}
+
+// rootComponent returns the absolute path of a root as a relative path for
+// the quarantine directory, with the volume name of Windows as its first
+// element.
+func rootComponent(root string) string {
+	vol := filepath.VolumeName(root)
+	return filepath.Join(strings.TrimSuffix(vol, ":"), strings.TrimLeft(root[len(vol):], `/\`))
+}
@@ -0,0 +1,40 @@
+package checker
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter spaces operations evenly to at most a fixed number per second,
+// a token bucket holding a single token. A nil limiter never waits.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time // earliest start of the next operation
+}
+
+// newLimiter returns a limiter for rate operations per second, nil for a
+// rate of 0 or less.
+func newLimiter(rate float64) *limiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &limiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// wait blocks until the next operation may start. It is safe for
+// concurrent use, the callers are served one interval apart.
+func (l *limiter) wait() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+	time.Sleep(time.Until(start))
+}
@@ -0,0 +1,264 @@
+package checker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/template"
+)
+
+// Report formats.
+const (
+	FormatText = "text" // human readable messages on stderr only
+	FormatJSON = "json" // one JSON document on stdout
+	FormatCSV  = "csv"  // one row per link on stdout
+
+	// FormatNDJSON streams a JSON object per line to stdout as soon as a
+	// link is inspected, with "type":"link". The last line has
+	// "type":"summary" and the counters of the run, preceded by a line with
+	// "type":"root_summary" per root with Checker.PerRootStats.
+	FormatNDJSON = "ndjson"
+
+	// FormatJUnit writes a JUnit XML document with a test case per link to
+	// stdout at the end of the run, broken links are failures.
+	FormatJUnit = "junit"
+
+	// FormatPrint0 writes the paths of broken links to stdout, each
+	// terminated by a NUL byte. The paths are relative to the working
+	// directory or absolute, so that they can be passed to xargs -0.
+	FormatPrint0 = "print0"
+)
+
+// reporter writes the inspected links in a structured format.
+type reporter interface {
+	add(link Link)
+	finish(stats Stats, roots []RootStats) error // roots is nil unless Checker.PerRootStats is set
+}
+
+// newReporter returns the reporter for format. With countOnly, the
+// entries of single links are left out and only the summary is written,
+// with onlyBroken the entries of links which are not broken. With list,
+// the text format writes the links with their targets to w, with tmpl the
+// output of tmpl per link.
+func newReporter(format string, w io.Writer, tmpl *template.Template, countOnly, onlyBroken, list bool) (reporter, error) {
+	var rep reporter
+	switch format {
+	case "", FormatText:
+		switch {
+		case tmpl != nil:
+			rep = &templateReporter{w: bufio.NewWriter(w), tmpl: tmpl}
+		case list:
+			rep = &listReporter{w: bufio.NewWriter(w)}
+		default:
+			return textReporter{}, nil
+		}
+	case FormatJSON:
+		r := &jsonReporter{w: w}
+		if !countOnly {
+			r.links = []Link{} // valid JSON array even without links
+		}
+		rep = r
+	case FormatCSV:
+		rep = newCSVReporter(w)
+	case FormatNDJSON:
+		rep = &ndjsonReporter{enc: json.NewEncoder(w)}
+	case FormatJUnit:
+		rep = &junitReporter{w: w}
+	case FormatPrint0:
+		rep = &print0Reporter{w: bufio.NewWriter(w)}
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	switch {
+	case countOnly:
+		rep = summaryOnly{rep}
+	case onlyBroken:
+		rep = brokenOnly{rep}
+	}
+	return rep, nil
+}
+
+// summaryOnly drops all links.
+type summaryOnly struct {
+	reporter
+}
+
+func (summaryOnly) add(Link) {}
+
+// brokenOnly drops the links which are not broken.
+type brokenOnly struct {
+	reporter
+}
+
+func (r brokenOnly) add(link Link) {
+	if link.Broken {
+		r.reporter.add(link)
+	}
+}
+
+// textReporter does nothing, human readable output is logged during the walk.
+type textReporter struct{}
+
+func (textReporter) add(Link)                        {}
+func (textReporter) finish(Stats, []RootStats) error { return nil }
+
+// listReporter writes a "path -> target" line per link.
+type listReporter struct {
+	w *bufio.Writer
+}
+
+func (r *listReporter) add(link Link) {
+	fmt.Fprintf(r.w, "%s -> %s\n", link.FullPath(), link.Target)
+}
+
+func (r *listReporter) finish(Stats, []RootStats) error {
+	return r.w.Flush()
+}
+
+// StatusNoSymlinks is the status of the structured summary if no link was
+// inspected at all, to tell an empty tree apart from a failed scan.
+const StatusNoSymlinks = "no_symlinks"
+
+// summary is the summary of the structured reports, the counters of the
+// run and an optional status.
+type summary struct {
+	Stats
+	Status string `json:"status,omitempty"`
+}
+
+func newSummary(stats Stats) summary {
+	s := summary{Stats: stats}
+	if stats.LinksInspected == 0 {
+		s.Status = StatusNoSymlinks
+	}
+	return s
+}
+
+// templateReporter writes the output of tmpl executed with each link,
+// terminated by a newline. Links with empty output are left out.
+type templateReporter struct {
+	w    *bufio.Writer
+	tmpl *template.Template
+	buf  bytes.Buffer
+	err  error // first execution error
+}
+
+func (r *templateReporter) add(link Link) {
+	if r.err != nil {
+		return
+	}
+	r.buf.Reset()
+	if r.err = r.tmpl.Execute(&r.buf, link); r.err != nil || r.buf.Len() == 0 {
+		return
+	}
+	if !bytes.HasSuffix(r.buf.Bytes(), []byte("\n")) {
+		r.buf.WriteByte('\n')
+	}
+	r.w.Write(r.buf.Bytes())
+}
+
+func (r *templateReporter) finish(Stats, []RootStats) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.w.Flush()
+}
+
+type jsonReporter struct {
+	w     io.Writer
+	links []Link
+}
+
+func (r *jsonReporter) add(link Link) {
+	r.links = append(r.links, link)
+}
+
+func (r *jsonReporter) finish(stats Stats, roots []RootStats) error {
+	enc := json.NewEncoder(r.w)
+	enc.SetIndent("", "  ")
+	if r.links == nil {
+		// summary only
+		return enc.Encode(struct {
+			Summary summary     `json:"summary"`
+			Roots   []RootStats `json:"roots,omitempty"`
+		}{newSummary(stats), roots})
+	}
+	return enc.Encode(struct {
+		Links   []Link      `json:"links"`
+		Summary summary     `json:"summary"`
+		Roots   []RootStats `json:"roots,omitempty"`
+	}{r.links, newSummary(stats), roots})
+}
+
+type ndjsonReporter struct {
+	enc *json.Encoder
+	err error // first write error
+}
+
+func (r *ndjsonReporter) add(link Link) {
+	if r.err != nil {
+		return
+	}
+	r.err = r.enc.Encode(struct {
+		Type string `json:"type"`
+		Link
+	}{"link", link})
+}
+
+func (r *ndjsonReporter) finish(stats Stats, roots []RootStats) error {
+	if r.err != nil {
+		return r.err
+	}
+	for _, root := range roots {
+		if err := r.enc.Encode(struct {
+			Type string `json:"type"`
+			RootStats
+		}{"root_summary", root}); err != nil {
+			return err
+		}
+	}
+	return r.enc.Encode(struct {
+		Type string `json:"type"`
+		summary
+	}{"summary", newSummary(stats)})
+}
+
+type csvReporter struct {
+	w *csv.Writer
+}
+
+func newCSVReporter(w io.Writer) *csvReporter {
+	r := &csvReporter{w: csv.NewWriter(w)}
+	r.w.Write([]string{"root", "path", "target", "resolved", "broken", "removed", "target_path"})
+	return r
+}
+
+func (r *csvReporter) add(link Link) {
+	r.w.Write([]string{link.Root, link.Path, link.Target, link.Resolved,
+		strconv.FormatBool(link.Broken), strconv.FormatBool(link.Removed), link.TargetPath})
+}
+
+func (r *csvReporter) finish(Stats, []RootStats) error {
+	r.w.Flush()
+	return r.w.Error()
+}
+
+type print0Reporter struct {
+	w *bufio.Writer
+}
+
+func (r *print0Reporter) add(link Link) {
+	if !link.Broken {
+		return
+	}
+	r.w.WriteString(link.FullPath())
+	r.w.WriteByte(0)
+}
+
+func (r *print0Reporter) finish(Stats, []RootStats) error {
+	return r.w.Flush()
+}
@@ -0,0 +1,264 @@
+package checker
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Reasons why a link is broken.
+const (
+	ReasonMissingTarget = "missing_target"
+	ReasonCycle         = "cycle"
+	ReasonSelf          = "self_reference" // the link points to itself, a cycle of length one
+)
+
+// Resolve modes, see Checker.ResolveMode.
+const (
+	ResolveFull   = "full"   // follow the whole chain with filepath.EvalSymlinks
+	ResolveTarget = "target" // only check that the direct target of a link exists
+)
+
+// errTimeout is returned by evalSymlinks if resolving takes longer than Checker.Timeout.
+var errTimeout = errors.New("timed out")
+
+// evalSymlinks is filepath.EvalSymlinks, or resolveTarget with
+// ResolveTarget, limited to Timeout. A resolution hanging on a dead mount
+// keeps blocking its goroutine in the background, but the scan goes on.
+func (c *Checker) evalSymlinks(path string) (string, error) {
+	resolve := filepath.EvalSymlinks
+	if c.dirCache != nil {
+		resolve = c.dirCache.evalSymlinks
+	}
+	if c.ResolveMode == ResolveTarget {
+		resolve = resolveTarget
+	}
+	c.limiter.wait()
+	if c.Timeout <= 0 {
+		return resolve(path)
+	}
+	type evalResult struct {
+		path string
+		err  error
+	}
+	done := make(chan evalResult, 1)
+	go func() {
+		resolved, err := resolve(path)
+		done <- evalResult{resolved, err}
+	}()
+	timer := time.NewTimer(c.Timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.path, r.err
+	case <-timer.C:
+		return "", errTimeout
+	}
+}
+
+// resolveTarget returns the absolute direct target of the link path if it
+// exists, whether it is a link itself or not. A link pointing to itself
+// fails with syscall.ELOOP, longer cycles are not detected.
+func resolveTarget(path string) (string, error) {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	target, err = filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	if abs, err := filepath.Abs(path); err == nil && abs == target {
+		return "", &fs.PathError{Op: "lstat", Path: target, Err: syscall.ELOOP}
+	}
+	if _, err := os.Lstat(target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// reportEscape flags the healthy link if its resolved path lies outside
+// of the root. Relative resolved paths are relative to the root.
+func (c *Checker) reportEscape(root rootDir, name, resolved string, res *result) {
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(root.real, resolved)
+	}
+	if within(root.real, resolved) {
+		return
+	}
+	c.event(LevelBroken, "escaping", fields{"path", name, "resolved", resolved},
+		"escaping link %s resolves outside of the root to %s", name, resolved)
+	res.link.Escapes = true
+}
+
+// reportEmpty flags the healthy link path if it resolves to a regular file
+// of size zero.
+func (c *Checker) reportEmpty(path, name, resolved string, res *result) {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() > 0 {
+		return
+	}
+	c.event(LevelBroken, "empty_target", fields{"path", name, "resolved", resolved},
+		"link %s resolves to the empty file %s", name, resolved)
+	res.link.EmptyTarget = true
+}
+
+// reportType flags the healthy link path if it does not resolve to a
+// regular file.
+func (c *Checker) reportType(path, name, resolved string, res *result) {
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode().IsRegular() {
+		return
+	}
+	kind := fileKind(fi.Mode())
+	c.event(LevelBroken, "type_mismatch", fields{"path", name, "resolved", resolved, "kind", kind, "mode", fi.Mode().String()},
+		"link %s resolves to the %s %s (%s), not a regular file", name, kind, resolved, fi.Mode())
+	res.link.TypeMismatch = true
+}
+
+// reportNotExecutable flags the healthy link path if it resolves to a
+// regular file without any execute bit.
+func (c *Checker) reportNotExecutable(path, name, resolved string, res *result) {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() || fi.Mode().Perm()&0o111 != 0 {
+		return
+	}
+	c.event(LevelBroken, "not_executable", fields{"path", name, "resolved", resolved, "mode", fi.Mode().String()},
+		"link %s resolves to %s (%s), which is not executable", name, resolved, fi.Mode())
+	res.link.NotExecutable = true
+}
+
+// fileKind describes the type of mode.
+func fileKind(mode fs.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode&fs.ModeCharDevice != 0:
+		return "character device"
+	case mode&fs.ModeDevice != 0:
+		return "device"
+	case mode&fs.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	}
+	return "file"
+}
+
+// brokenAncestor returns the first symlink below root among the parent
+// directories of the target of the link path which is broken itself, or
+// an empty string. Such a link breaks all links pointing below it.
+func brokenAncestor(root, path, target string) string {
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	dir := filepath.Dir(target)
+	if !within(root, dir) {
+		return ""
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return ""
+	}
+	ancestor := root
+	for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+		ancestor = filepath.Join(ancestor, elem)
+		fi, err := os.Lstat(ancestor)
+		if err != nil {
+			return "" // a missing directory, not a broken link
+		}
+		if fi.Mode()&fs.ModeSymlink == 0 {
+			continue
+		}
+		if _, err := os.Stat(ancestor); err != nil {
+			return ancestor
+		}
+	}
+	return ""
+}
+
+// chainLength returns the number of links in the chain starting at path,
+// following each with os.Readlink until a path is no link. Links in the
+// parent directories of the targets are not counted. Like brokenReason, a
+// cycle stops the count.
+func chainLength(path string) int {
+	visited := make(map[string]bool)
+	n := 0
+	for {
+		abs, err := filepath.Abs(path)
+		if err != nil || visited[abs] {
+			return n
+		}
+		visited[abs] = true
+		target, err := os.Readlink(path)
+		if err != nil {
+			return n // no link or missing
+		}
+		n++
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		path = target
+	}
+}
+
+// reportDepth flags the healthy link path if more than MaxResolveDepth
+// links have to be followed to reach its target.
+func (c *Checker) reportDepth(path, name, resolved string, res *result) {
+	n := chainLength(path)
+	if n <= c.MaxResolveDepth {
+		return
+	}
+	c.event(LevelBroken, "over_depth", fields{"path", name, "resolved", resolved, "hops", n},
+		"link %s needs %d hops to resolve to %s, more than %d", name, n, resolved, c.MaxResolveDepth)
+	res.link.OverDepth = true
+}
+
+// brokenReason follows the chain of links starting at path with os.Readlink
+// and reports why it could not be resolved. A link visited twice is a cycle,
+// unless path itself is its own target.
+func brokenReason(path string) string {
+	visited := make(map[string]bool)
+	for {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return ReasonMissingTarget
+		}
+		if visited[abs] {
+			return ReasonCycle
+		}
+		visited[abs] = true
+
+		fi, err := os.Lstat(path)
+		if err != nil {
+			// a cycle in an intermediate directory component
+			if errors.Is(err, syscall.ELOOP) {
+				return ReasonCycle
+			}
+			return ReasonMissingTarget
+		}
+		if fi.Mode()&fs.ModeSymlink == 0 {
+			return ReasonMissingTarget
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return ReasonMissingTarget
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		if len(visited) == 1 {
+			if t, err := filepath.Abs(target); err == nil && t == abs {
+				return ReasonSelf
+			}
+		}
+		path = target
+	}
+}
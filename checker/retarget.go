@@ -0,0 +1,47 @@
+package checker
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// replaceTarget retargets the link path if its target starts with the path
+// ReplaceFrom, replacing this prefix by ReplaceTo. The prefix matches whole
+// path elements only, "/mnt/old" matches "/mnt/old/f" but not "/mnt/older".
+// It reports whether the target matched.
+func (c *Checker) replaceTarget(path, name, target string, res *result) bool {
+	rest, ok := cutPathPrefix(target, c.ReplaceFrom)
+	if !ok {
+		return false
+	}
+	newTarget := c.ReplaceTo + rest
+	if c.DryRun {
+		c.event(LevelBroken, "retargeted", fields{"path", name, "target", target, "new_target", newTarget, "dry_run", true},
+			"would retarget %s: %s => %s", name, target, newTarget)
+		res.link.Retargeted = true
+		return true
+	}
+	c.logf(LevelBroken, "Retarget link %s -> %s", name, newTarget)
+	if err := relink(path, newTarget); err != nil {
+		res.errors++
+		c.errorf("Could not retarget %s: %v", name, err)
+		return true
+	}
+	c.dirCache.invalidate()
+	res.link.Retargeted = true
+	return true
+}
+
+// cutPathPrefix returns the remainder of path after prefix, including the
+// leading separator, if path equals prefix or lies below it.
+func cutPathPrefix(path, prefix string) (string, bool) {
+	prefix = strings.TrimSuffix(prefix, string(filepath.Separator))
+	if prefix == "" || !strings.HasPrefix(path, prefix) {
+		return "", false
+	}
+	rest := path[len(prefix):]
+	if rest != "" && rest[0] != filepath.Separator {
+		return "", false
+	}
+	return rest, true
+}
@@ -0,0 +1,30 @@
+package checker
+
+import (
+	"errors"
+	"syscall"
+	"time"
+)
+
+// retryDelay is the wait before the first retry, it doubles with every attempt.
+const retryDelay = 50 * time.Millisecond
+
+// transient reports whether err is likely to go away on retry,
+// like a stale NFS handle.
+func transient(err error) bool {
+	return errors.Is(err, syscall.ESTALE) || errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR)
+}
+
+// retry calls op until it succeeds, fails with a non-transient error or
+// Retries retries are used up. Retries are delayed with exponential backoff.
+func (c *Checker) retry(what, name string, op func() error) error {
+	delay := retryDelay
+	err := op()
+	for i := 1; i <= c.Retries && err != nil && transient(err); i++ {
+		c.logf(LevelOK, "retry %d/%d to %s %s in %s: %v", i, c.Retries, what, name, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+		err = op()
+	}
+	return err
+}
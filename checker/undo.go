@@ -0,0 +1,47 @@
+package checker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// UndoEntry is a line of an undo log, it records a removed link.
+type UndoEntry struct {
+	Path   string `json:"path"`   // absolute path of the removed link
+	Target string `json:"target"` // raw contents of the removed link
+}
+
+// writeUndo appends the removed link to the UndoLog.
+func (c *Checker) writeUndo(link Link) error {
+	path, err := filepath.Abs(link.FullPath())
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(UndoEntry{Path: path, Target: link.Target})
+	if err != nil {
+		return err
+	}
+	_, err = c.UndoLog.Write(append(data, '\n'))
+	return err
+}
+
+// ReadUndoLog returns the entries of the undo log r in the order the links
+// were removed.
+func ReadUndoLog(r io.Reader) ([]UndoEntry, error) {
+	var entries []UndoEntry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e UndoEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
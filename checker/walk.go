@@ -0,0 +1,53 @@
+package checker
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// walkPostOrder walks the file tree rooted at root like filepath.WalkDir,
+// but calls leave for each directory after all of its entries have been
+// walked. fn is still called for a directory before its entries, so that
+// it can skip the directory by returning filepath.SkipDir.
+func walkPostOrder(root string, fn fs.WalkDirFunc, leave func(path string)) error {
+	fi, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDirPostOrder(root, fs.FileInfoToDirEntry(fi), fn, leave)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func walkDirPostOrder(path string, d fs.DirEntry, fn fs.WalkDirFunc, leave func(path string)) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir && d.IsDir() {
+			err = nil // do not descend
+		}
+		return err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		// as WalkDir, report the error and go on with the entries read
+		if err := fn(path, d, err); err != nil {
+			if err == filepath.SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+	for _, e := range entries {
+		if err := walkDirPostOrder(filepath.Join(path, e.Name()), e, fn, leave); err != nil {
+			if err == filepath.SkipDir {
+				break // skip the remaining entries of path
+			}
+			return err
+		}
+	}
+	leave(path)
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// Subcommands, check is assumed if the first argument is none of them.
+const (
+	cmdCheck   = "check"   // all flags, the default
+	cmdList    = "list"    // like check -list
+	cmdFix     = "fix"     // retarget or rewrite links
+	cmdVersion = "version" // like check -version
+)
+
+// commonFlags are accepted by the list and fix subcommands besides their
+// own flags, check accepts all flags.
+var commonFlags = []string{
+	"quiet", "v", "vv", "max-depth", "no-recurse", "follow", "one-file-system", "continue-on-error",
+	"exclude", "exclude-regexp", "include", "skip-hidden", "ignore-file", "since",
+	"absolute", "output-relative-to", "trim-prefix", "sort", "format", "template", "out", "log-format", "color",
+	"workers", "root-workers", "rate", "progress", "progress-interval", "no-fail", "exit-code-errors",
+}
+
+var commandFlags = map[string][]string{
+	cmdList: {"dedup-inodes", "post-order", "count-only"},
+	cmdFix: {"fix-to", "replace-target", "make-relative", "make-absolute", "touch-targets",
+		"dry-run", "force", "resolve-mode", "timeout", "retries", "allow-broken-file", "exit-code-broken", "fail-on-error"},
+}
+
+// splitCommand returns the subcommand given in args and the remaining
+// arguments. Use ./check to check a directory with the name of a subcommand.
+func splitCommand(args []string) (string, []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case cmdCheck, cmdList, cmdFix, cmdVersion:
+			return args[0], args[1:]
+		}
+	}
+	return cmdCheck, args
+}
+
+// commandFlagSet returns a flag set for the list or fix subcommand cmd
+// with the commonFlags and the commandFlags of cmd. The flags share their
+// values with the flags of all.
+func commandFlagSet(all *flag.FlagSet, cmd string) *flag.FlagSet {
+	fs := flag.NewFlagSet("checksymlinks "+cmd, flag.ExitOnError)
+	for _, name := range append(commonFlags, commandFlags[cmd]...) {
+		f := all.Lookup(name)
+		fs.Var(f.Value, f.Name, f.Usage)
+	}
+	fs.Usage = func() {
+		fmt.Printf("Usage:\n    checksymlinks %s [flags] <directory>...\n\nFlags:\n", cmd)
+		fs.PrintDefaults()
+	}
+	return fs
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// completion writes the completion script for the shell given in args and
+// returns the exit status.
+func completion(fs *flag.FlagSet, args []string, w io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: checksymlinks completion bash|zsh|fish")
+		return 1
+	}
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(fs, w)
+	case "zsh":
+		writeZshCompletion(fs, w)
+	case "fish":
+		writeFishCompletion(fs, w)
+	default:
+		fmt.Fprintf(os.Stderr, "Unsupported shell %s, use bash, zsh or fish\n", args[0])
+		return 1
+	}
+	return exitOK
+}
+
+// completionFlag is a flag as offered by the completion scripts.
+type completionFlag struct {
+	name    string
+	desc    string // first sentence of the usage
+	hasArg  bool
+	argName string
+}
+
+func completionFlags(fs *flag.FlagSet) []completionFlag {
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		argName, usage := flag.UnquoteUsage(f)
+		usage = firstSentence(usage)
+		bf, isBool := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{
+			name:    f.Name,
+			desc:    usage,
+			hasArg:  !isBool || !bf.IsBoolFlag(),
+			argName: argName,
+		})
+	})
+	return flags
+}
+
+// abbreviations end in a dot without ending a sentence of a usage.
+var abbreviations = []string{"e.g", "i.e", "etc"}
+
+// firstSentence returns s up to the first dot followed by a space which does
+// not end an abbreviation.
+func firstSentence(s string) string {
+	for i := 0; ; {
+		j := strings.Index(s[i:], ". ")
+		if j < 0 {
+			return s
+		}
+		end := i + j
+		if !endsInAbbreviation(s[:end]) {
+			return s[:end]
+		}
+		i = end + 2
+	}
+}
+
+func endsInAbbreviation(s string) bool {
+	for _, abbr := range abbreviations {
+		if rest := strings.TrimSuffix(s, abbr); rest != s && (rest == "" || strings.HasSuffix(rest, " ") || strings.HasSuffix(rest, "(")) {
+			return true
+		}
+	}
+	return false
+}
+
+// completionCommand is a subcommand with the flags offered after it.
+type completionCommand struct {
+	name  string
+	flags []completionFlag
+}
+
+// completionCommands returns the subcommands with flags, check with all
+// flags of fs. The version subcommand has no flags.
+func completionCommands(fs *flag.FlagSet) []completionCommand {
+	return []completionCommand{
+		{cmdCheck, completionFlags(fs)},
+		{cmdList, completionFlags(commandFlagSet(fs, cmdList))},
+		{cmdFix, completionFlags(commandFlagSet(fs, cmdFix))},
+	}
+}
+
+var subcommands = []string{cmdCheck, cmdList, cmdFix, cmdVersion}
+
+func writeBashCompletion(fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintf(w, `# bash completion for checksymlinks, load with: source <(checksymlinks completion bash)
+_checksymlinks() {
+	local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}
+	local cmd=%s flags argflags
+	case ${COMP_WORDS[1]} in
+	%s)
+		cmd=${COMP_WORDS[1]}
+		;;
+	esac
+	case $cmd in
+`, cmdCheck, strings.Join(subcommands, "|"))
+	for _, c := range completionCommands(fs) {
+		var names, argFlags []string
+		for _, f := range c.flags {
+			names = append(names, "-"+f.name)
+			if f.hasArg {
+				argFlags = append(argFlags, "-"+f.name)
+			}
+		}
+		fmt.Fprintf(w, "\t%s)\n\t\tflags=\"%s\"\n\t\targflags=\"%s\"\n\t\t;;\n",
+			c.name, strings.Join(names, " "), strings.Join(argFlags, " "))
+	}
+	fmt.Fprintf(w, `	%s)
+		return
+		;;
+	esac
+	case " $argflags " in
+	*" $prev "*)
+		COMPREPLY=($(compgen -f -- "$cur"))
+		return
+		;;
+	esac
+	if [[ $cur == -* ]]; then
+		COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+	elif ((COMP_CWORD == 1)); then
+		COMPREPLY=($(compgen -W "%s" -- "$cur") $(compgen -d -- "$cur"))
+	else
+		COMPREPLY=($(compgen -d -- "$cur"))
+	fi
+}
+complete -o filenames -F _checksymlinks checksymlinks
+`, cmdVersion, strings.Join(subcommands, " "))
+}
+
+func writeZshCompletion(fs *flag.FlagSet, w io.Writer) {
+	escape := strings.NewReplacer(`'`, `'\''`, `[`, `\[`, `]`, `\]`)
+	fmt.Fprintln(w, "#compdef checksymlinks")
+	fmt.Fprintln(w, "# zsh completion for checksymlinks, save as _checksymlinks in a directory of $fpath")
+	for _, c := range completionCommands(fs) {
+		fmt.Fprintf(w, "_checksymlinks_%s() {\n", c.name)
+		fmt.Fprintln(w, "  _arguments \\")
+		for _, f := range c.flags {
+			if f.hasArg {
+				fmt.Fprintf(w, "    '-%s[%s]:%s:_files' \\\n", f.name, escape.Replace(f.desc), f.argName)
+			} else {
+				fmt.Fprintf(w, "    '-%s[%s]' \\\n", f.name, escape.Replace(f.desc))
+			}
+		}
+		fmt.Fprintln(w, "    '*:directory:_files -/'")
+		fmt.Fprintln(w, "}")
+	}
+	fmt.Fprintf(w, `_checksymlinks() {
+  if ((CURRENT == 2)) && [[ $words[2] != -* ]]; then
+    _alternative 'subcommands:subcommand:(%s)' 'directories:directory:_files -/'
+    return
+  fi
+  case $words[2] in
+  %s|%s|%s)
+    local cmd=$words[2]
+    shift words
+    ((CURRENT--))
+    _checksymlinks_$cmd
+    ;;
+  %s)
+    ;;
+  *)
+    _checksymlinks_%s
+    ;;
+  esac
+}
+_checksymlinks "$@"
+`, strings.Join(subcommands, " "), cmdCheck, cmdList, cmdFix, cmdVersion, cmdCheck)
+}
+
+func writeFishCompletion(fs *flag.FlagSet, w io.Writer) {
+	escape := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	others := strings.Join(subcommands[1:], " ")
+	fmt.Fprintln(w, "# fish completion for checksymlinks, load with: checksymlinks completion fish | source")
+	fmt.Fprintf(w, "complete -c checksymlinks -f -n '__fish_use_subcommand' -a '%s'\n", strings.Join(subcommands, " "))
+	fmt.Fprintf(w, "complete -c checksymlinks -f -n 'not __fish_seen_subcommand_from %s' -a '(__fish_complete_directories)'\n", cmdVersion)
+	for _, c := range completionCommands(fs) {
+		// check is also the default without a subcommand
+		cond := "__fish_seen_subcommand_from " + c.name
+		if c.name == cmdCheck {
+			cond = "not __fish_seen_subcommand_from " + others
+		}
+		for _, f := range c.flags {
+			if f.hasArg {
+				fmt.Fprintf(w, "complete -c checksymlinks -n '%s' -o %s -r -F -d '%s'\n", cond, f.name, escape.Replace(f.desc))
+			} else {
+				fmt.Fprintf(w, "complete -c checksymlinks -n '%s' -o %s -d '%s'\n", cond, f.name, escape.Replace(f.desc))
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/erwiese/checksymlinks/checker"
+)
+
+// confirmSample is the number of links shown before asking for confirmation.
+const confirmSample = 10
+
+// systemDirs are the directories in which links are only removed as root
+// with -i-know-what-i-am-doing.
+var systemDirs = []string{"/", "/bin", "/boot", "/etc", "/lib", "/sbin", "/usr", "/var"}
+
+// systemRoot returns the first of roots which is, contains or lies below
+// one of the systemDirs, with its symlinks resolved, or "" if there is
+// none. Only "/" itself counts, not the paths below it.
+func systemRoot(roots []string) string {
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = real
+		}
+		for _, dir := range systemDirs {
+			if isBelow(abs, dir) || dir != "/" && isBelow(dir, abs) {
+				return root
+			}
+		}
+	}
+	return ""
+}
+
+// isBelow reports whether the absolute path sub lies below or is equal to dir.
+func isBelow(dir, sub string) bool {
+	rel, err := filepath.Rel(dir, sub)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// confirmRemoval runs chk as a silent dry run, shows the links which would
+// be removed and asks on the terminal whether to proceed. It reports
+// whether the user agreed.
+func confirmRemoval(chk *checker.Checker) (bool, error) {
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false, errors.New("stdin is not a terminal, run without -confirm to remove links non-interactively")
+	}
+
+	removed, err := previewRemoval(chk)
+	if err != nil {
+		return false, err
+	}
+	if len(removed) == 0 {
+		return true, nil // nothing to confirm
+	}
+
+	printSample(removed)
+	fmt.Fprintf(os.Stderr, "Proceed with deletion of %d links? [y/N] ", len(removed))
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return false, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// previewRemoval runs chk as a silent dry run and returns the links which
+// would be removed.
+func previewRemoval(chk *checker.Checker) ([]string, error) {
+	var removed []string
+	dry := *chk
+	dry.DryRun = true
+	dry.CountOnly = true
+	dry.Format = checker.FormatText
+	dry.Progress = 0
+	dry.OnBroken = nil
+	dry.OnRemoved = func(path string) {
+		removed = append(removed, path)
+	}
+	if _, err := dry.Run(context.Background()); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// printSample shows the first confirmSample links of removed on stderr.
+func printSample(removed []string) {
+	for i, path := range removed {
+		if i == confirmSample {
+			fmt.Fprintf(os.Stderr, "  ... and %d more\n", len(removed)-confirmSample)
+			break
+		}
+		fmt.Fprintf(os.Stderr, "  %s\n", path)
+	}
+}
@@ -1,33 +1,155 @@
 package main
 
-// see https://stackoverflow.com/questions/45022633/resolving-broken-symbolic-links
-
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"syscall"
+	"text/template"
 	"time"
+	"unicode"
+
+	"github.com/erwiese/checksymlinks/checker"
 )
 
 const version = "0.1.2"
 
-var beQuiet bool
+// Build information, set with
+// go build -ldflags "-X main.commit=$(git rev-parse --short HEAD) -X main.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	commit string
+	date   string
+)
+
+// Exit codes
+const (
+	exitOK     = 0
+	exitBroken = 2 // broken links found
+	exitErrors = 3 // errors occurred, takes precedence over exitBroken
+
+	exitInterrupted = 130 // stopped by SIGINT or SIGTERM
+)
 
 func main() {
 	startTime := time.Now()
 
 	fs := flag.NewFlagSet("checksymlinks", flag.ExitOnError)
-	quiet := fs.Bool("quiet", false, "suppress non-error messages")
+	quiet := fs.Bool("quiet", false, "only report broken links, modifications and errors. Overrides -v and -vv")
+	var verbosity int
+	fs.Var(countFlag{&verbosity, 1}, "v", "verbose: also report healthy links. Can be repeated")
+	fs.Var(countFlag{&verbosity, 2}, "vv", "more verbose: also report visited directories")
 	delBrokenLinks := fs.Bool("delete-broken", false, "If true, all broken symbolic links will be removed. Use with care! Defaults to false")
-	delAllLinks := fs.Bool("delete-all", false, "If true, all symbolic links will be removed. Use with care! Defaults to false")
+	delAllLinks := fs.Bool("delete-all", false, "If true, all symbolic links will be removed. Use with care! Without -force, -confirm or -dry-run only the number of links is shown. Defaults to false")
+	iKnow := fs.Bool("i-know-what-i-am-doing", false, "allow -delete-broken and -delete-all as root in system directories like /usr or /etc, below them or in /")
+	force := fs.Bool("force", false, "really remove all symbolic links with -delete-all and really retarget links with -replace-target")
+	quietIfClean := fs.Bool("quiet-if-clean", false, "print nothing, not even the summary, if no broken links were found and no errors occurred. Messages are held back until the end of the run. For cron jobs")
+	noFail := fs.Bool("no-fail", false, "always exit with status 0 if the traversal completes, even if broken links were found or errors occurred")
+	exitCodeBroken := fs.Int("exit-code-broken", exitBroken, "exit with status `N` if broken links were found, 0 to 125")
+	exitCodeErrors := fs.Int("exit-code-errors", exitErrors, "exit with status `N` if errors occurred, 0 to 125")
+	failOnError := fs.Bool("fail-on-error", false, "exit with the -exit-code-errors status if errors occurred, but with 0 if broken links were only found. Takes precedence over -no-fail")
+	confirm := fs.Bool("confirm", false, "together with -delete-broken or -delete-all first show the links which would be removed and ask for confirmation on the terminal")
+	dryRun := fs.Bool("dry-run", false, "together with -delete-broken or -delete-all only report which links would be removed, together with -make-relative or -make-absolute show the new targets")
+	noRecurse := fs.Bool("no-recurse", false, "only check the links directly in the root directory, same as -max-depth 1")
+	maxDepth := fs.Int("max-depth", -1, "descend at most `N` directory levels below the root directory, 0 means the root itself. -1 means unlimited")
+	replaceTarget := fs.String("replace-target", "", "retarget links whose target starts with the path `from=to`, e.g. /mnt/old=/mnt/new, broken or not. Without -force the links are only shown as with -dry-run")
+	fixTo := fs.String("fix-to", "", "retarget each broken link to the file in `dir` with the same base name as the link target, if there is exactly one")
+	makeRelative := fs.Bool("make-relative", false, "rewrite links with an absolute target below the root directory as relative links")
+	makeAbsolute := fs.Bool("make-absolute", false, "rewrite links with a relative target as absolute links")
+	pruneEmpty := fs.Bool("prune-empty", false, "remove directories left empty by removing or quarantining broken links, never the root directories")
+	pruneEmptyAll := fs.Bool("prune-empty-all", false, "like prune-empty, but also remove directories which were empty before")
+	touchTargets := fs.Bool("touch-targets", false, "create an empty file at the missing target of each broken link, if the target is relative and below the root directory")
+	quarantine := fs.String("quarantine", "", "move broken links into `dir` instead of removing them, below the absolute path of their root directory")
+	follow := fs.Bool("follow", false, "follow symbolic links to directories and check the links below them. Directories are visited only once")
+	continueOnError := fs.Bool("continue-on-error", false, "log and count paths which cannot be accessed, e.g. unreadable directories, and go on instead of aborting")
+	watchInterval := fs.Duration("watch", 0, "run again every `interval`, e.g. 5m, and print a summary line per run until interrupted")
+	watchOnChange := fs.Bool("watch-changes", false, "with -watch, only print the summary if the number of broken links changed")
+	maxBroken := fs.Int("max-broken", 0, "stop the traversal once `N` broken links were found, 0 means no limit")
+	linkStats := fs.Bool("link-stats", false, "print the total, minimum, average and maximum length of the link targets")
+	dedupInodes := fs.Bool("dedup-inodes", false, "inspect a link reachable through several paths, e.g. bind mounts or overlapping roots, only once. Not supported on Windows")
+	postOrder := fs.Bool("post-order", false, "process the contents of a directory before the directory itself, like find -depth")
+	oneFileSystem := fs.Bool("one-file-system", false, "do not descend into directories on other file systems than the root directory, like find -xdev. Not supported on Windows")
+	showProgress := fs.Bool("progress", false, "periodically print the number of scanned files, inspected links and broken links to stderr")
+	progressInterval := fs.Duration("progress-interval", 2*time.Second, "`interval` between two progress lines")
+	reportEscapes := fs.Bool("report-escapes", false, "report links resolving to a path outside of the root directory")
+	maxResolveDepth := fs.Int("max-resolve-depth", 0, "report links which resolve only through a chain of more than `N` links, 0 disables the check")
+	expectExecutable := fs.Bool("expect-executable", false, "report links resolving to a file without execute permission, e.g. in bin directories. Not supported on Windows")
+	expectFile := fs.Bool("expect-file", false, "report links resolving to a directory, device or other non-regular file")
+	reportEmpty := fs.Bool("report-empty-targets", false, "report links resolving to a zero-byte file, often the remnant of a failed copy")
+	minAge := fs.Duration("min-age", 0, "only remove or quarantine broken links last modified at least `duration` ago, e.g. 24h")
+	since := fs.String("since", "", "only inspect links modified after `time`, an RFC3339 timestamp or a duration ago like 24h. Older links are not counted")
+	onlyBroken := fs.Bool("only-broken", false, "only include broken links in the json, ndjson and csv reports. The summary still counts all links")
+	countOnly := fs.Bool("count-only", false, "only print the summary, no messages about single links. Errors are still reported")
+	retries := fs.Int("retries", 0, "retry resolving and removing a link up to `N` times with exponential backoff on transient errors like ESTALE or EAGAIN")
+	print0 := fs.Bool("print0", false, "write the paths of broken links to stdout, each terminated by a NUL byte, instead of logging them. For use with xargs -0")
+	rateLimit := fs.Float64("rate", 0, "perform at most `N` directory reads, link resolutions and removals per second, e.g. to spare a busy NFS server. 0 means no limit")
+	timeout := fs.Duration("timeout", 0, "give up resolving a single link after `duration`, e.g. on a dead mount. Timed out links are counted separately and never removed")
+	statsByExt := fs.Bool("stats-by-ext", false, "print the number of broken links per file extension after the summary")
+	uniqueTargets := fs.Bool("unique-targets", false, "log each broken target once with the number of links referencing it instead of each broken link")
+	groupByTarget := fs.Bool("group-by-target", false, "print the number of broken links per target directory after the summary")
+	colorMode := fs.String("color", "auto", "color broken and removed links in messages `when`: auto, always or never. auto colors only on a terminal and if NO_COLOR is not set")
+	logFormat := fs.String("log-format", checker.LogFormatText, "`format` of log messages: text or logfmt. logfmt writes key=value lines with stable keys for log aggregators")
+	perRootSummary := fs.Bool("per-root-summary", false, "also print the counters of each root directory. The json and ndjson reports include them as roots")
+	manifest := fs.String("manifest", "", "write all links with their targets, sorted and with a hash, to `file`, see -compare-manifest")
+	compareManifest := fs.String("compare-manifest", "", "report the links added, removed or retargeted since the manifest `file` was written")
+	out := fs.String("out", "", "write the report to `file` instead of stdout, messages still go to stderr")
+	undoLog := fs.String("undo-log", "", "append the path and target of each removed link to `file`, see -restore. Directories removed by -prune-empty are recreated by -restore, but with mode 0755")
+	restoreFrom := fs.String("restore", "", "recreate the links recorded in the undo log `file` instead of checking directories")
+	promFile := fs.String("prom-file", "", "write the summary counters as Prometheus metrics to `file`, e.g. for the textfile collector of node_exporter")
+	statsFile := fs.String("stats-file", "", "write the summary counters and the elapsed time as JSON to `file`")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of concurrent workers resolving symbolic links")
+	rootWorkers := fs.Int("root-workers", 1, "number of root directories traversed concurrently, e.g. when they are on different disks")
+	list := fs.Bool("list", false, "only list all symbolic links with their targets on stdout, without resolving, removing or changing them")
+	sortLinks := fs.Bool("sort", false, "report the links sorted by path after the traversal instead of as they are found. Keeps all links in memory")
+	trimPrefix := fs.String("trim-prefix", "", "remove `prefix` from the paths in messages, e.g. a long build directory given with -absolute")
+	relativeTo := fs.String("output-relative-to", "", "report paths relative to `dir` instead of the root directory, absolute paths if not below it")
+	absolute := fs.Bool("absolute", false, "report absolute paths instead of paths relative to the root directory")
+	tmplText := fs.String("template", "", "write a line per link with the Go text/template `template`, e.g. '{{.Path}} -> {{.Target}}'. Fields are those of the json report like .Path, .Target, .Resolved and .Broken. Requires the text format")
+	format := formatFlag{value: checker.FormatText}
+	fs.Var(&format, "format", "report `format`: text, json, ndjson, csv or junit. The json, ndjson, csv and junit reports are written to stdout, messages go to stderr")
+	var networkPrefixes stringList
+	resolveMode := fs.String("resolve-mode", checker.ResolveFull, "`mode` of checking links: full reports a link as broken if any link on the way to its final target is broken, target only if its own direct target is missing")
+	flagNetwork := fs.Bool("flag-network-targets", false, "report links whose target lies below a -network-prefix or is a UNC path on Windows")
+	fs.Var(&networkPrefixes, "network-prefix", "`path` of network mounts for -flag-network-targets, defaults to /net and /mnt/nfs. Can be repeated")
+	var excludes, excludeRegexps stringList
+	fs.Var(&excludes, "exclude", "skip paths matching the shell `pattern`, matched against the relative path and its base name. Can be repeated")
+	fs.Var(&excludeRegexps, "exclude-regexp", "skip paths matching the regular `expression`, matched against the relative path. Can be repeated")
+	var includes stringList
+	fs.Var(&includes, "include", "only inspect symlinks matching the shell `pattern`, matched against the relative path and its base name. Can be repeated. Excludes take precedence")
+	allowBrokenFile := fs.String("allow-broken-file", "", "`file` with shell patterns of broken links that are expected, one per line. They are neither counted as broken nor removed")
+	skipHidden := fs.Bool("skip-hidden", false, "skip files and directories whose name starts with a dot, except the root directories")
+	ignoreFile := fs.String("ignore-file", ".checksymlinksignore", "`name` of files with gitignore-style patterns of paths to skip, applied to the directory they are found in and below. Empty disables ignore files")
 	fs.Usage = func() {
 		fmt.Println(`checksymlinks - traverse a directory recursive and search for broken links.
 	
 Usage:
-    checksymlinks [flags] <directory>
+    checksymlinks [check] [flags] <directory>...
+    A symbolic link given instead of a directory is checked on its own.
+    A directory "-" reads the directories from stdin, one per line.
+    Blank lines and lines starting with # are ignored.
+    checksymlinks list [flags] <directory>...
+    List all links with their targets, like -list. See checksymlinks list -h for its flags.
+    checksymlinks fix [flags] <directory>...
+    Retarget or rewrite links with -fix-to, -replace-target, -make-relative, -make-absolute
+    or -touch-targets. See checksymlinks fix -h for its flags.
+    checksymlinks version
+    Print the version and build information.
+    Use ./check, ./list, ./fix or ./version to check a directory with the name of a subcommand.
+    checksymlinks completion bash|zsh|fish
+    Print a shell completion script. Use ./completion to check a directory of this name.
 	
 Flags:`)
 		fs.PrintDefaults()
@@ -35,23 +157,136 @@ Flags:`)
 Examples:
     Report broken links
     $ checksymlinks /home/user/xyz/dir1
+
+    Report broken links in several directories
+    $ checksymlinks /opt /srv /home/user/xyz/dir1
 	
     Delete broken links
     $ checksymlinks -delete-broken /home/user/xyz/dir1
 
+    Move broken links out of the way instead of deleting them
+    $ checksymlinks -quarantine /home/user/quarantine /home/user/xyz/dir1
+
+    Delete broken links and restore them later
+    $ checksymlinks -delete-broken -undo-log undo.log /home/user/xyz/dir1
+    $ checksymlinks -restore undo.log
+
+    Show which broken links would be deleted
+    $ checksymlinks -delete-broken -dry-run /home/user/xyz/dir1
+
+    Skip version control and dependency directories
+    $ checksymlinks -exclude .git -exclude node_modules /home/user/xyz/dir1
+
+    Check only links to shared libraries
+    $ checksymlinks -include '*.so' -include '*.so.*' /usr/lib
+
+    Retarget broken links to files that moved to another directory
+    $ checksymlinks -fix-to /home/user/xyz/newdir /home/user/xyz/dir1
+
+    Find links pointing outside of a web root
+    $ checksymlinks -report-escapes /var/www
+
+    Check the directories listed in a file
+    $ cat dirlist.txt | checksymlinks -
+
+    Delete broken links with another tool
+    $ checksymlinks -print0 /home/user/xyz/dir1 | xargs -0 rm
+
+    Write a JSON report
+    $ checksymlinks -quiet -format json /home/user/xyz/dir1 > report.json
+
+Environment:
+    CHECKSYMLINKS_OPTS  flags applied before the command line flags, e.g. "-quiet -exclude .git".
+                        Words may be quoted with ' or ". Flags given on the command line take precedence,
+                        repeatable flags like -exclude accumulate.
+
+Exit status:
+    0  no broken links found and no errors occurred
+    1  invalid arguments or the traversal failed
+    2  broken links found, see -exit-code-broken
+    3  errors occurred, e.g. a link could not be removed (takes precedence over 2), see -exit-code-errors
+    130 interrupted by SIGINT or SIGTERM, the summary covers the links inspected so far
+    Use -no-fail to exit with 0 in the cases 2 and 3.
+    Use -fail-on-error to exit with 0 in case 2 only, it takes precedence over -no-fail.
 	`)
 		fmt.Printf("checksymlinks v%s %s\n", version, "https://github.com/erwiese/checksymlinks")
 	}
 
-	fs.Parse(os.Args[1:])
-	beQuiet = *quiet
+	printVersion := fs.Bool("version", false, "print the version and build information and exit")
+	if len(os.Args) > 1 && os.Args[1] == "completion" {
+		os.Exit(completion(fs, os.Args[2:], os.Stdout))
+	}
+	cmd, args := splitCommand(os.Args[1:])
+	if cmd == cmdVersion {
+		fmt.Println(versionString())
+		os.Exit(exitOK)
+	}
+	all := fs
+	if cmd != cmdCheck {
+		fs = commandFlagSet(all, cmd)
+	}
+	// Default flags from the environment, overridden by the command line.
+	if opts := os.Getenv("CHECKSYMLINKS_OPTS"); opts != "" {
+		envArgs, err := splitArgs(opts)
+		if err == nil {
+			all.Parse(envArgs)
+			if all.NArg() > 0 {
+				err = fmt.Errorf("unexpected argument %q", all.Arg(0))
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid CHECKSYMLINKS_OPTS: %v\n", err)
+			os.Exit(1)
+		}
+		format.set = false // the command line may choose another format
+	}
+	fs.Parse(args)
+	switch cmd {
+	case cmdList:
+		*list = true
+	case cmdFix:
+		if *fixTo == "" && *replaceTarget == "" && !*makeRelative && !*makeAbsolute && !*touchTargets {
+			fmt.Fprintf(os.Stderr, "Subcommand fix needs one of the flags fix-to, replace-target, make-relative, make-absolute or touch-targets\n")
+			fs.Usage()
+			os.Exit(1)
+		}
+	}
+	if *printVersion {
+		fmt.Println(versionString())
+		os.Exit(exitOK)
+	}
+	if *restoreFrom != "" {
+		os.Exit(restore(*restoreFrom))
+	}
 	argsNotParsed := fs.Args()
-	if len(argsNotParsed) > 1 {
-		fmt.Fprintf(os.Stderr, "unknown arguments: %s\n", strings.Join(argsNotParsed, " "))
+	if len(argsNotParsed) < 1 {
+		fmt.Fprintf(os.Stderr, "No root path given\n")
 		fs.Usage()
 		os.Exit(1)
-	} else if len(argsNotParsed) < 1 {
-		fmt.Fprintf(os.Stderr, "No root path given\n")
+	}
+
+	var replaceFrom, replaceTo string
+	if *replaceTarget != "" {
+		var ok bool
+		replaceFrom, replaceTo, ok = strings.Cut(*replaceTarget, "=")
+		if !ok || replaceFrom == "" {
+			fmt.Fprintf(os.Stderr, "Invalid replace-target %q, must be from=to\n", *replaceTarget)
+			fs.Usage()
+			os.Exit(1)
+		}
+		if *delAllLinks {
+			fmt.Fprintf(os.Stderr, "Flag replace-target is not allowed together with delete-all\n")
+			fs.Usage()
+			os.Exit(1)
+		}
+	}
+	if *flagNetwork && len(networkPrefixes) == 0 {
+		networkPrefixes = stringList{"/net", "/mnt/nfs"}
+	}
+	// The preview is a dry run of the whole Checker.
+	previewRetarget := replaceFrom != "" && !*force && !*dryRun
+	if previewRetarget && (*delBrokenLinks || *fixTo != "" || *quarantine != "" || *touchTargets || *makeRelative || *makeAbsolute || *pruneEmpty || *pruneEmptyAll) {
+		fmt.Fprintf(os.Stderr, "Flag replace-target without -force or -dry-run is not allowed together with other flags changing links\n")
 		fs.Usage()
 		os.Exit(1)
 	}
@@ -62,104 +297,767 @@ Examples:
 		os.Exit(1)
 	}
 
-	rootDir := argsNotParsed[0]
-	if _, err := os.Stat(rootDir); os.IsNotExist(err) {
-		log.Fatalf("Path %s does not exist", rootDir)
+	if (*makeRelative || *makeAbsolute) && (*delBrokenLinks || *delAllLinks) {
+		fmt.Fprintf(os.Stderr, "Flags make-relative and make-absolute are not allowed together with delete-broken or delete-all\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *quarantine != "" && (*delBrokenLinks || *delAllLinks) {
+		fmt.Fprintf(os.Stderr, "Flag quarantine is not allowed together with delete-broken or delete-all\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *print0 {
+		if format.set && format.value != checker.FormatPrint0 {
+			fmt.Fprintf(os.Stderr, "Flag print0 is not allowed together with format %s\n", format.value)
+			fs.Usage()
+			os.Exit(1)
+		}
+		format.value = checker.FormatPrint0
+	}
+	if *touchTargets && (*delBrokenLinks || *delAllLinks || *quarantine != "") {
+		fmt.Fprintf(os.Stderr, "Flag touch-targets is not allowed together with delete-broken, delete-all or quarantine\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *watchInterval > 0 && (*confirm || *quietIfClean || *manifest != "" || *compareManifest != "") {
+		fmt.Fprintf(os.Stderr, "Flag watch is not allowed together with confirm, quiet-if-clean, manifest or compare-manifest\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+	var tmpl *template.Template
+	if *tmplText != "" {
+		if format.value != checker.FormatText {
+			fmt.Fprintf(os.Stderr, "Flag template is not allowed together with format %s\n", format.value)
+			fs.Usage()
+			os.Exit(1)
+		}
+		var err error
+		if tmpl, err = template.New("template").Parse(*tmplText); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid template: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *absolute && *relativeTo != "" {
+		fmt.Fprintf(os.Stderr, "Flags absolute and output-relative-to are not allowed together\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *makeRelative && *makeAbsolute {
+		fmt.Fprintf(os.Stderr, "Flags make-relative and make-absolute are not allowed together\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *pruneEmptyAll {
+		*pruneEmpty = true
+	}
+	for _, code := range []struct {
+		flag  string
+		value int
+	}{{"exit-code-broken", *exitCodeBroken}, {"exit-code-errors", *exitCodeErrors}} {
+		if code.value < 0 || code.value > 125 {
+			fmt.Fprintf(os.Stderr, "Invalid %s %d, must be between 0 and 125\n", code.flag, code.value)
+			fs.Usage()
+			os.Exit(1)
+		}
+	}
+	codes := exitCodes{broken: *exitCodeBroken, errors: *exitCodeErrors}
+	if *noRecurse {
+		if *maxDepth >= 0 && *maxDepth != 1 {
+			fmt.Fprintf(os.Stderr, "Flag no-recurse is not allowed together with max-depth %d\n", *maxDepth)
+			fs.Usage()
+			os.Exit(1)
+		}
+		*maxDepth = 1
+	}
+	if *list && (*delBrokenLinks || *delAllLinks || *makeRelative || *makeAbsolute || *fixTo != "" || replaceFrom != "" || *quarantine != "" || *pruneEmpty) {
+		fmt.Fprintf(os.Stderr, "Flag list is not allowed together with flags changing links\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	switch *logFormat {
+	case checker.LogFormatText:
+	case checker.LogFormatLogfmt:
+		log.SetFlags(0) // each line has its own time key
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown log format %s\n", *logFormat)
+		fs.Usage()
+		os.Exit(1)
 	}
 
-	err := os.Chdir(rootDir)
+	color, err := useColor(*colorMode)
 	if err != nil {
-		log.Fatalf("Could not change to root-dir %s: %v", rootDir, err)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var rootDirs []string
+	for _, arg := range argsNotParsed {
+		if arg != "-" {
+			rootDirs = append(rootDirs, arg)
+			continue
+		}
+		dirs, err := readLines(os.Stdin)
+		if err != nil {
+			log.Fatalf("Could not read directories from stdin: %v", err)
+		}
+		rootDirs = append(rootDirs, dirs...)
+	}
+	if len(rootDirs) < 1 {
+		fmt.Fprintf(os.Stderr, "No root path given\n")
+		os.Exit(1)
+	}
+	for i, rootDir := range rootDirs {
+		rootDir, err := expandPath(rootDir)
+		if err != nil {
+			log.Fatalf("Could not expand %s: %v", rootDirs[i], err)
+		}
+		rootDirs[i] = rootDir
+		if _, err := os.Lstat(rootDir); os.IsNotExist(err) {
+			// a trailing separator makes Lstat follow a link
+			if target, err := os.Readlink(filepath.Clean(rootDir)); err == nil {
+				log.Fatalf("Root %s is a broken symlink pointing to %s", rootDir, target)
+			}
+			log.Fatalf("Path %s does not exist", rootDir)
+		}
+	}
+	// Messages held back with -quiet-if-clean until it is known whether the
+	// run is clean.
+	var held bytes.Buffer
+	previewDeleteAll := *delAllLinks && !*force && !*confirm && !*dryRun
+	if (*delBrokenLinks || *delAllLinks) && !*dryRun && !previewDeleteAll && os.Geteuid() == 0 {
+		if *quietIfClean {
+			log.SetOutput(&held)
+		}
+		log.Print("WARNING: running as root, links will be removed with root privileges")
+		log.SetOutput(os.Stderr)
+		if dir := systemRoot(rootDirs); dir != "" && !*iKnow {
+			log.Fatalf("Refusing to remove links in the system directory %s as root, use -i-know-what-i-am-doing to proceed", dir)
+		}
 	}
-	debug(fmt.Sprintf("root dir: %s", rootDir))
 
-	nofErrors := 0
-	nofBrokenLinks := 0
-	nofLinksRemoved := 0
-	nofLinksInspected := 0
+	var excludeRes []*regexp.Regexp
+	for _, expr := range excludeRegexps {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid exclude-regexp %q: %v\n", expr, err)
+			os.Exit(1)
+		}
+		excludeRes = append(excludeRes, re)
+	}
 
-	// Traverse directory recursive, does not follow links
-	// TODO use the new WalkDir function in Go1.16
-	err = filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+	var allowBroken []string
+	if *allowBrokenFile != "" {
+		f, err := os.Open(*allowBrokenFile)
+		if err != nil {
+			log.Fatalf("Could not open allow-broken file: %v", err)
+		}
+		allowBroken, err = readLines(f)
+		f.Close()
 		if err != nil {
-			fmt.Printf("prevent panic by handling failure accessing a path %q: %v\n", path, err)
-			return err
+			log.Fatalf("Could not read allow-broken file: %v", err)
 		}
+	}
 
-		if info.IsDir() {
-			debug(fmt.Sprintf("visited dir: %q", path))
-			return nil
+	var sinceTime time.Time
+	if *since != "" {
+		t, err := parseSince(*since, startTime)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid since %q: %v\n", *since, err)
+			os.Exit(1)
 		}
+		sinceTime = t
+	}
 
-		//fmt.Printf("visited file or dir: %q\n", path)
-		fi, err := os.Lstat(path)
+	var progress time.Duration
+	if *showProgress {
+		progress = *progressInterval
+	}
+
+	if *quiet {
+		verbosity = checker.LevelBroken
+	}
+
+	chk := &checker.Checker{
+		Roots:            rootDirs,
+		DeleteBroken:     *delBrokenLinks,
+		DeleteAll:        *delAllLinks,
+		Verbosity:        verbosity,
+		Format:           format.value,
+		Template:         tmpl,
+		Rate:             *rateLimit,
+		Workers:          *workers,
+		RootWorkers:      *rootWorkers,
+		Absolute:         *absolute,
+		RelativeTo:       *relativeTo,
+		TrimPrefix:       *trimPrefix,
+		DryRun:           *dryRun || previewRetarget,
+		MaxDepth:         libraryDepth(*maxDepth),
+		FixTo:            *fixTo,
+		ReplaceFrom:      replaceFrom,
+		ReplaceTo:        replaceTo,
+		MakeRelative:     *makeRelative,
+		MakeAbsolute:     *makeAbsolute,
+		Quarantine:       *quarantine,
+		TouchTargets:     *touchTargets,
+		PruneEmpty:       *pruneEmpty,
+		PruneEmptyAll:    *pruneEmptyAll,
+		Follow:           *follow,
+		OneFileSystem:    *oneFileSystem,
+		PostOrder:        *postOrder,
+		DedupInodes:      *dedupInodes,
+		MaxBroken:        *maxBroken,
+		ContinueOnError:  *continueOnError,
+		Progress:         progress,
+		ReportEscapes:    *reportEscapes,
+		ResolveMode:      *resolveMode,
+		FlagNetwork:      *flagNetwork,
+		NetworkPrefixes:  networkPrefixes,
+		ReportEmpty:      *reportEmpty,
+		ExpectFile:       *expectFile,
+		ExpectExecutable: *expectExecutable,
+		MaxResolveDepth:  *maxResolveDepth,
+		MinAge:           *minAge,
+		Since:            sinceTime,
+		Sort:             *sortLinks,
+		List:             *list,
+		UniqueTargets:    *uniqueTargets,
+		CountOnly:        *countOnly,
+		OnlyBroken:       *onlyBroken,
+		Retries:          *retries,
+		Timeout:          *timeout,
+		Exclude:          excludes,
+		ExcludeRegexp:    excludeRes,
+		Include:          includes,
+		IgnoreFile:       *ignoreFile,
+		SkipHidden:       *skipHidden,
+		AllowBroken:      allowBroken,
+		LogFormat:        *logFormat,
+		Color:            color && *logFormat == checker.LogFormatText,
+	}
+	var oldManifest []checker.ManifestEntry
+	if *compareManifest != "" {
+		var err error
+		oldManifest, err = readManifestFile(*compareManifest)
+		if err != nil {
+			log.Fatalf("Could not read manifest %s: %v", *compareManifest, err)
+		}
+	}
+	chk.RecordManifest = *manifest != "" || *compareManifest != ""
+	chk.PerRootStats = *perRootSummary
+	var outFile *os.File
+	if *out != "" {
+		var err error
+		outFile, err = os.Create(*out)
+		if err != nil {
+			log.Fatalf("Could not create report file: %v", err)
+		}
+		chk.Stdout = outFile
+	}
+	if *undoLog != "" {
+		f, err := os.OpenFile(*undoLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
 		if err != nil {
-			log.Fatalf("Could not get stat for %s: %v", path, err)
-		}
-
-		// If path is a symlink
-		if fi.Mode()&os.ModeSymlink != 0 {
-			nofLinksInspected++
-			// remove link anyway
-			if *delAllLinks {
-				log.Printf("Remove link %s", path)
-				err = os.Remove(path)
-				if err != nil {
-					nofErrors++
-					log.Printf("Could not remove %s: %v", path, err)
-				}
-				nofLinksRemoved++
-				return nil
+			log.Fatalf("Could not open undo log: %v", err)
+		}
+		defer f.Close()
+		chk.UndoLog = f
+	}
+	if previewDeleteAll {
+		removed, err := previewRemoval(chk)
+		if err != nil {
+			log.Fatalf("Could not count the links: %v", err)
+		}
+		printSample(removed)
+		fmt.Fprintf(os.Stderr, "This will remove %d symlinks under %s. Re-run with -force to proceed.\n",
+			len(removed), strings.Join(rootDirs, ", "))
+		os.Exit(exitOK)
+	}
+	if *confirm && !*dryRun && (*delBrokenLinks || *delAllLinks) {
+		ok, err := confirmRemoval(chk)
+		if err != nil {
+			log.Fatalf("Could not confirm: %v", err)
+		}
+		if !ok {
+			log.Print("aborted, no links were removed")
+			os.Exit(exitOK)
+		}
+	}
+	// Stop the walk on Ctrl-C and print what was found so far.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *watchInterval > 0 {
+		watch(ctx, chk, watchOptions{
+			interval:  *watchInterval,
+			onChange:  *watchOnChange,
+			logfmt:    *logFormat == checker.LogFormatLogfmt,
+			dryRun:    *dryRun,
+			statsFile: *statsFile,
+			promFile:  *promFile,
+		})
+		log.Print("watch stopped")
+		os.Exit(exitOK)
+	}
+	// Hold back all messages until it is known whether the run is clean.
+	if *quietIfClean {
+		log.SetOutput(&held)
+		chk.Stderr = &held
+	}
+	stats, err := chk.Run(ctx)
+	interrupted := errors.Is(err, context.Canceled)
+	aborted := errors.Is(err, checker.ErrMaxBroken)
+	if err != nil && !interrupted && !aborted {
+		releaseLog(&held)
+		log.Fatalf("error walking the paths %q: %v", rootDirs, err)
+	}
+	if outFile != nil {
+		if err := outFile.Close(); err != nil {
+			releaseLog(&held)
+			log.Fatalf("Could not write report file: %v", err)
+		}
+	}
+	if *statsFile != "" {
+		if err := writeStatsFile(*statsFile, stats, time.Since(startTime)); err != nil {
+			stats.Errors++
+			log.Printf("Could not write stats file: %v", err)
+		}
+	}
+	var manifestDiff checker.ManifestDiff
+	if *compareManifest != "" && !interrupted && !aborted {
+		manifestDiff = checker.CompareManifests(oldManifest, chk.Manifest())
+	}
+	if *manifest != "" && !interrupted && !aborted {
+		if hash, err := writeManifestFile(*manifest, chk.Manifest()); err != nil {
+			stats.Errors++
+			log.Printf("Could not write manifest: %v", err)
+		} else if *logFormat == checker.LogFormatLogfmt {
+			log.Print(checker.Logfmt("info", "manifest", "file", *manifest, "sha256", hash))
+		} else {
+			log.Printf("manifest %s written, sha256 %s", *manifest, hash)
+		}
+	}
+	if *promFile != "" {
+		if err := writePromFile(*promFile, stats, time.Since(startTime)); err != nil {
+			stats.Errors++
+			log.Printf("Could not write prom file: %v", err)
+		}
+	}
+	if *quietIfClean {
+		if stats.BrokenLinks == 0 && stats.Errors == 0 && !interrupted {
+			os.Exit(exitOK)
+		}
+		releaseLog(&held)
+	}
+
+	printManifestDiff(manifestDiff, *logFormat == checker.LogFormatLogfmt)
+	if *perRootSummary {
+		printRootStats(chk.RootStats(), *logFormat == checker.LogFormatLogfmt)
+	}
+	if *logFormat == checker.LogFormatLogfmt {
+		log.Print(logfmtSummary(stats, time.Since(startTime), *dryRun, interrupted))
+		if aborted {
+			log.Print(checker.Logfmt("info", "aborted", "max_broken", *maxBroken, "broken", stats.BrokenLinks))
+		}
+		if *groupByTarget {
+			printTargetDirs(chk.BrokenLinks(), true)
+		}
+		if *statsByExt {
+			printExtensions(chk.BrokenLinks(), true)
+		}
+	} else if *list {
+		log.Printf("%-16s %d", "listed links:", stats.LinksInspected)
+		log.Printf("%-16s %d", "errors:", stats.Errors)
+		log.Printf("Execution time: %s", time.Since(startTime).String())
+	} else {
+		if stats.LinksInspected == 0 && stats.Errors == 0 && !interrupted && !*quiet {
+			log.Printf("No symlinks found under %s", strings.Join(chk.Roots, ", "))
+		}
+		log.Printf("%-16s %d", "inspected links:", stats.LinksInspected)
+		log.Printf("%-16s %d", "removed links:", stats.LinksRemoved)
+		if color && stats.BrokenLinks > 0 {
+			log.Printf("%s%-16s %d%s", colorRed, "broken links:", stats.BrokenLinks, colorReset)
+		} else {
+			log.Printf("%-16s %d", "broken links:", stats.BrokenLinks)
+		}
+		log.Printf("%-16s %d", "circular links:", stats.CircularLinks)
+		log.Printf("%-16s %d", "self-referential links:", stats.SelfLinks)
+		if stats.CascadedLinks > 0 {
+			log.Printf("%-16s %d", "cascaded (skipped):", stats.CascadedLinks)
+		}
+		if *allowBrokenFile != "" {
+			log.Printf("%-16s %d", "allowed broken:", stats.AllowedLinks)
+		}
+		if *fixTo != "" {
+			log.Printf("%-16s %d", "fixed links:", stats.FixedLinks)
+		}
+		if *makeRelative || *makeAbsolute {
+			log.Printf("%-16s %d", "converted links:", stats.ConvertedLinks)
+		}
+		if replaceFrom != "" {
+			log.Printf("%-16s %d", "retargeted links:", stats.RetargetedLinks)
+		}
+		if *timeout > 0 {
+			log.Printf("%-16s %d", "timed out links:", stats.TimedOutLinks)
+		}
+		if *reportEscapes {
+			log.Printf("%-16s %d", "escaping links:", stats.EscapingLinks)
+		}
+		if *reportEmpty {
+			log.Printf("%-16s %d", "empty-target links:", stats.EmptyTargetLinks)
+		}
+		if *expectFile {
+			log.Printf("%-16s %d", "type-mismatch links:", stats.TypeMismatches)
+		}
+		if *flagNetwork {
+			log.Printf("%-16s %d", "network-target links:", stats.NetworkLinks)
+		}
+		if *maxResolveDepth > 0 {
+			log.Printf("%-16s %d", "over-depth links:", stats.OverDepthLinks)
+		}
+		if *expectExecutable {
+			log.Printf("%-16s %d", "non-executable-target links:", stats.NonExecutable)
+		}
+		if *quarantine != "" {
+			log.Printf("%-16s %d", "quarantined links:", stats.QuarantinedLinks)
+		}
+		if *touchTargets {
+			log.Printf("%-16s %d", "placeholders created:", stats.Placeholders)
+		}
+		if *pruneEmpty {
+			log.Printf("%-16s %d", "directories pruned:", stats.PrunedDirs)
+		}
+		if *compareManifest != "" {
+			log.Printf("%-16s %d", "manifest changes:", manifestDiff.Len())
+		}
+		if *dedupInodes {
+			log.Printf("%-16s %d", "duplicates skipped:", stats.DuplicateLinks)
+		}
+		if *linkStats {
+			printLinkStats(stats)
+		}
+		log.Printf("%-16s %d", "errors:", stats.Errors)
+		if stats.PermissionErrors > 0 {
+			log.Printf("%-16s %d", "permission errors:", stats.PermissionErrors)
+		}
+		if previewRetarget && stats.RetargetedLinks > 0 {
+			log.Print("preview: re-run with -force to retarget the links")
+		}
+		if *dryRun && (*delBrokenLinks || *delAllLinks) {
+			log.Print("dry run: removed links were not actually removed")
+		}
+		if *dryRun && (*makeRelative || *makeAbsolute) {
+			log.Print("dry run: converted links were not actually converted")
+		}
+		if *groupByTarget {
+			printTargetDirs(chk.BrokenLinks(), false)
+		}
+		if *statsByExt {
+			printExtensions(chk.BrokenLinks(), false)
+		}
+
+		elapsed := time.Since(startTime)
+		log.Printf("Execution time: %s", elapsed.String())
+		if aborted {
+			log.Printf("aborted after %d broken links: the summary is incomplete", stats.BrokenLinks)
+		}
+		if interrupted {
+			log.Print("interrupted: the summary is incomplete")
+		}
+	}
+
+	if interrupted {
+		os.Exit(exitInterrupted)
+	}
+
+	switch {
+	case *failOnError:
+		os.Exit(exitCode(stats, false, codes))
+	case !*noFail:
+		os.Exit(exitCode(stats, true, codes))
+	}
+}
+
+// versionString returns the version with the commit and the build date.
+// Without ldflags they are taken from the VCS information embedded by the
+// go command, if available.
+func versionString() string {
+	rev, built := commit, date
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && rev == "":
+				rev = s.Value
+			case s.Key == "vcs.time" && built == "":
+				built = s.Value
 			}
+		}
+	}
+	if rev == "" {
+		rev = "unknown"
+	}
+	if built == "" {
+		built = "unknown"
+	}
+	return fmt.Sprintf("checksymlinks v%s (commit %s, built %s)", version, rev, built)
+}
+
+// releaseLog writes the messages held back in buf to stderr and logs
+// to stderr directly from now on.
+func releaseLog(held *bytes.Buffer) {
+	log.SetOutput(os.Stderr)
+	os.Stderr.Write(held.Bytes())
+	held.Reset()
+}
 
-			// check if link is broken
-			resolvedPath, err := filepath.EvalSymlinks(path)
-			if err != nil {
-				log.Printf("broken link %s: %v", path, err)
-				nofBrokenLinks++
-				if *delBrokenLinks {
-					log.Printf("Remove broken link %s", path)
-					err = os.Remove(path)
-					if err != nil {
-						nofErrors++
-						log.Printf("Could not remove broken link %s: %v", path, err)
-					}
-					nofLinksRemoved++
-				}
+// splitArgs splits s into words separated by whitespace. Words may be
+// quoted with single or double quotes, a backslash escapes the next
+// character outside of single quotes.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
 			} else {
-				debug(fmt.Sprintf("symlink %s OK", resolvedPath))
+				word.WriteRune(r)
 			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case unicode.IsSpace(r):
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
+
+// readLines returns the lines of r, e.g. a list of directories or patterns.
+// Blank lines and comments starting with # are skipped.
+func readLines(r io.Reader) ([]string, error) {
+	var dirs []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
 		}
+		dirs = append(dirs, line)
+	}
+	return dirs, scanner.Err()
+}
+
+// expandPath replaces a leading "~" of p by the home directory of the user
+// and expands environment variables like $VAR and ${VAR}, as a shell would.
+func expandPath(p string) (string, error) {
+	p = os.ExpandEnv(p)
+	if p != "~" && !strings.HasPrefix(p, "~/") && !strings.HasPrefix(p, "~"+string(filepath.Separator)) {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, p[1:]), nil
+}
+
+// parseSince parses an RFC3339 timestamp or a duration before now.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.New("neither an RFC3339 timestamp nor a duration")
+	}
+	return t, nil
+}
+
+// writeStatsFile writes the counters of a run as JSON to the file path.
+func writeStatsFile(path string, stats checker.Stats, elapsed time.Duration) error {
+	data, err := json.MarshalIndent(struct {
+		checker.Stats
+		ElapsedSeconds float64 `json:"elapsed_seconds"`
+	}{stats, elapsed.Seconds()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// writePromFile writes the counters of a run in the Prometheus text
+// exposition format to the file path. The file is replaced atomically, so
+// that a collector never reads a partial file.
+func writePromFile(path string, stats checker.Stats, elapsed time.Duration) error {
+	var buf bytes.Buffer
+	metric := func(name, help string, value interface{}) {
+		fmt.Fprintf(&buf, "# HELP checksymlinks_%s %s\n", name, help)
+		fmt.Fprintf(&buf, "# TYPE checksymlinks_%s gauge\n", name)
+		fmt.Fprintf(&buf, "checksymlinks_%s %v\n", name, value)
+	}
+	metric("inspected", "Number of symbolic links inspected by the last run.", stats.LinksInspected)
+	metric("broken_links", "Number of broken symbolic links found by the last run.", stats.BrokenLinks)
+	metric("removed_links", "Number of symbolic links removed by the last run.", stats.LinksRemoved)
+	metric("errors", "Number of errors during the last run.", stats.Errors)
+	metric("duration_seconds", "Duration of the last run in seconds.", elapsed.Seconds())
+	metric("last_run_timestamp_seconds", "Unix time the last run finished.", time.Now().Unix())
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".checksymlinks-*.prom")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	// CreateTemp uses mode 0600, the collector may run as another user
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// stringList is a flag that can be given multiple times.
+type stringList []string
 
-		return nil
-	})
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
 
+// countFlag is a boolean flag that adds step to count each time it is given.
+type countFlag struct {
+	count *int
+	step  int
+}
+
+func (f countFlag) String() string {
+	if f.count == nil {
+		return "0"
+	}
+	return strconv.Itoa(*f.count)
+}
+
+func (f countFlag) Set(value string) error {
+	v, err := strconv.ParseBool(value)
 	if err != nil {
-		log.Fatalf("error walking the path %q: %v", rootDir, err)
+		return err
+	}
+	if v {
+		*f.count += f.step
+	}
+	return nil
+}
+
+func (f countFlag) IsBoolFlag() bool {
+	return true
+}
+
+// formatFlag is the report format, it can only be given once.
+type formatFlag struct {
+	value string
+	set   bool
+}
+
+func (f *formatFlag) String() string {
+	return f.value
+}
+
+func (f *formatFlag) Set(value string) error {
+	if f.set && value != f.value {
+		return fmt.Errorf("formats %s and %s are mutually exclusive", f.value, value)
 	}
+	f.value, f.set = value, true
+	return nil
+}
 
-	// switch mode := fi.Mode(); {
-	// case mode.IsRegular():
-	// 	fmt.Println("regular file")
-	// case mode.IsDir():
-	// 	fmt.Println("directory")
-	// case mode&os.ModeSymlink != 0:
-	// 	fmt.Println("symbolic link")
-	// case mode&os.ModeNamedPipe != 0:
-	// 	fmt.Println("named pipe")
-	// }
+// ANSI escape sequences
+const (
+	colorRed   = "\x1b[31m"
+	colorReset = "\x1b[0m"
+)
 
-	log.Printf("%-16s %d", "inspected links:", nofLinksInspected)
-	log.Printf("%-16s %d", "removed links:", nofLinksRemoved)
-	log.Printf("%-16s %d", "broken links:", nofBrokenLinks)
-	log.Printf("%-16s %d", "errors:", nofErrors)
+// useColor reports whether messages are colored in mode auto, always or
+// never. With auto, stderr must be a terminal and NO_COLOR must not be set.
+func useColor(mode string) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		fi, err := os.Stderr.Stat()
+		return err == nil && fi.Mode()&os.ModeCharDevice != 0, nil
+	}
+	return false, fmt.Errorf("unknown color mode %s", mode)
+}
 
-	elapsed := time.Since(startTime)
-	log.Printf("Execution time: %s", elapsed.String())
+// exitCodes are the exit statuses of failed runs, exitBroken and
+// exitErrors unless set by flags.
+type exitCodes struct {
+	broken int
+	errors int
+}
+
+// exitCode returns the exit status for the result of a run. Broken
+// links only make the run fail with brokenFails.
+func exitCode(stats checker.Stats, brokenFails bool, codes exitCodes) int {
+	switch {
+	case stats.Errors > 0:
+		return codes.errors
+	case stats.BrokenLinks > 0 && brokenFails:
+		return codes.broken
+	}
+	return exitOK
 }
 
-func debug(text string) {
-	if !beQuiet {
-		log.Print(text)
+// libraryDepth translates the value of -max-depth, where 0 means the root
+// itself and -1 unlimited, to Checker.MaxDepth.
+func libraryDepth(maxDepth int) int {
+	switch {
+	case maxDepth < 0:
+		return 0
+	case maxDepth == 0:
+		return -1
 	}
+	return maxDepth
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/erwiese/checksymlinks/checker"
+)
+
+// writeManifestFile writes the manifest of entries to path and returns its hash.
+func writeManifestFile(path string, entries []checker.ManifestEntry) (string, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	hash, err := checker.WriteManifest(f, entries)
+	if err != nil {
+		f.Close()
+		return "", err
+	}
+	return hash, f.Close()
+}
+
+// readManifestFile returns the entries of the manifest at path.
+func readManifestFile(path string) ([]checker.ManifestEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return checker.ReadManifest(f)
+}
+
+// printManifestDiff logs the links which changed since the manifest was written.
+func printManifestDiff(d checker.ManifestDiff, logfmt bool) {
+	for _, e := range d.Added {
+		if logfmt {
+			log.Print(checker.Logfmt("info", "manifest_added", "path", e.Path, "target", e.Target))
+		} else {
+			log.Printf("added link %s -> %s", e.Path, e.Target)
+		}
+	}
+	for _, e := range d.Removed {
+		if logfmt {
+			log.Print(checker.Logfmt("info", "manifest_removed", "path", e.Path, "target", e.Target))
+		} else {
+			log.Printf("removed link %s -> %s", e.Path, e.Target)
+		}
+	}
+	for _, r := range d.Retargeted {
+		if logfmt {
+			log.Print(checker.Logfmt("info", "manifest_retargeted", "path", r.Path, "old_target", r.OldTarget, "new_target", r.NewTarget))
+		} else {
+			log.Printf("retargeted link %s: %s -> %s", r.Path, r.OldTarget, r.NewTarget)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/erwiese/checksymlinks/checker"
+)
+
+// restore recreates the links recorded in the undo log file path and
+// returns the exit status. Links whose path is taken again are not
+// overwritten and count as errors. Missing parent directories, e.g. pruned
+// with -prune-empty, are created with mode 0755. Directories pruned with
+// -prune-empty-all which were empty before are not recorded and stay gone.
+func restore(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("Could not open undo log: %v", err)
+		return 1
+	}
+	defer f.Close()
+	entries, err := checker.ReadUndoLog(f)
+	if err != nil {
+		log.Printf("Could not read undo log %s: %v", path, err)
+		return 1
+	}
+
+	var restored, errs int
+	for _, e := range entries {
+		if err := os.MkdirAll(filepath.Dir(e.Path), 0o755); err != nil {
+			errs++
+			log.Printf("Could not restore %s: %v", e.Path, err)
+			continue
+		}
+		if err := os.Symlink(e.Target, e.Path); err != nil {
+			errs++
+			log.Printf("Could not restore %s: %v", e.Path, err)
+			continue
+		}
+		restored++
+		log.Printf("Restored link %s -> %s", e.Path, e.Target)
+	}
+	log.Printf("%-16s %d", "restored links:", restored)
+	log.Printf("%-16s %d", "errors:", errs)
+	if errs > 0 {
+		return exitErrors
+	}
+	return exitOK
+}
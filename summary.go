@@ -0,0 +1,139 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/erwiese/checksymlinks/checker"
+)
+
+// countEntry is a line of a histogram.
+type countEntry struct {
+	key   string
+	count int
+}
+
+// sortedCounts returns the entries of counts sorted by count in
+// descending order, entries with equal counts are sorted by key.
+func sortedCounts(counts map[string]int) []countEntry {
+	entries := make([]countEntry, 0, len(counts))
+	for key, count := range counts {
+		entries = append(entries, countEntry{key, count})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return entries[i].key < entries[j].key
+	})
+	return entries
+}
+
+// printTargetDirs logs how many broken links point into each directory.
+// Relative targets are resolved against the directory of the link.
+// With logfmt each directory is logged as a target_dir event.
+func printTargetDirs(broken []checker.BrokenLink, logfmt bool) {
+	counts := make(map[string]int)
+	for _, link := range broken {
+		target := link.Target
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(link.Path), target)
+		}
+		counts[filepath.Dir(target)]++
+	}
+	if logfmt {
+		for _, e := range sortedCounts(counts) {
+			log.Print(checker.Logfmt("info", "target_dir", "dir", e.key, "broken_links", e.count))
+		}
+		return
+	}
+	log.Print("broken links by target directory:")
+	for _, e := range sortedCounts(counts) {
+		log.Printf("%8d %s", e.count, e.key)
+	}
+}
+
+// printExtensions logs how many broken links have each file extension.
+// Links without an extension are counted as (none).
+func printExtensions(broken []checker.BrokenLink, logfmt bool) {
+	counts := make(map[string]int)
+	for _, link := range broken {
+		ext := filepath.Ext(link.Path)
+		if ext == "" {
+			ext = "(none)"
+		}
+		counts[ext]++
+	}
+	if logfmt {
+		for _, e := range sortedCounts(counts) {
+			log.Print(checker.Logfmt("info", "extension", "ext", e.key, "broken_links", e.count))
+		}
+		return
+	}
+	log.Print("broken links by extension:")
+	for _, e := range sortedCounts(counts) {
+		log.Printf("%8d %s", e.count, e.key)
+	}
+}
+
+// logfmtSummary returns the summary event of a run. The keys are those
+// of the JSON report and the stats file, all counters are always included.
+func logfmtSummary(stats checker.Stats, elapsed time.Duration, dryRun, interrupted bool) string {
+	return checker.Logfmt("info", "summary",
+		"links_inspected", stats.LinksInspected,
+		"links_removed", stats.LinksRemoved,
+		"broken_links", stats.BrokenLinks,
+		"circular_links", stats.CircularLinks,
+		"self_referential_links", stats.SelfLinks,
+		"cascaded_links", stats.CascadedLinks,
+		"allowed_broken_links", stats.AllowedLinks,
+		"fixed_links", stats.FixedLinks,
+		"converted_links", stats.ConvertedLinks,
+		"retargeted_links", stats.RetargetedLinks,
+		"quarantined_links", stats.QuarantinedLinks,
+		"placeholders_created", stats.Placeholders,
+		"pruned_dirs", stats.PrunedDirs,
+		"duplicate_links", stats.DuplicateLinks,
+		"target_bytes", stats.TargetBytes,
+		"min_target_length", stats.MinTargetLength,
+		"max_target_length", stats.MaxTargetLength,
+		"escaping_links", stats.EscapingLinks,
+		"empty_target_links", stats.EmptyTargetLinks,
+		"type_mismatch_links", stats.TypeMismatches,
+		"non_executable_target_links", stats.NonExecutable,
+		"over_depth_links", stats.OverDepthLinks,
+		"network_target_links", stats.NetworkLinks,
+		"timed_out_links", stats.TimedOutLinks,
+		"errors", stats.Errors,
+		"permission_errors", stats.PermissionErrors,
+		"dry_run", dryRun,
+		"interrupted", interrupted,
+		"elapsed_seconds", elapsed.Seconds(),
+	)
+}
+
+// printRootStats logs the main counters of each root. Errors of removing
+// empty directories are only counted in the summary.
+func printRootStats(roots []checker.RootStats, logfmt bool) {
+	for _, r := range roots {
+		if logfmt {
+			log.Print(checker.Logfmt("info", "root_summary", "root", r.Root, "links_inspected", r.LinksInspected,
+				"links_removed", r.LinksRemoved, "broken_links", r.BrokenLinks, "errors", r.Errors))
+			continue
+		}
+		log.Printf("root %s: inspected %d, removed %d, broken %d, errors %d",
+			r.Root, r.LinksInspected, r.LinksRemoved, r.BrokenLinks, r.Errors)
+	}
+}
+
+// printLinkStats logs the lengths of the link targets.
+func printLinkStats(stats checker.Stats) {
+	avg := 0.0
+	if stats.LinksInspected > 0 {
+		avg = float64(stats.TargetBytes) / float64(stats.LinksInspected)
+	}
+	log.Printf("%-16s %d links, %d bytes, min %d, avg %.1f, max %d", "link targets:",
+		stats.LinksInspected, stats.TargetBytes, stats.MinTargetLength, avg, stats.MaxTargetLength)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/erwiese/checksymlinks/checker"
+)
+
+// watchOptions control the repeated runs of -watch.
+type watchOptions struct {
+	interval  time.Duration
+	onChange  bool // only log a summary if the number of broken links changed
+	logfmt    bool
+	dryRun    bool
+	statsFile string
+	promFile  string
+}
+
+// watch runs chk every interval until ctx is cancelled and logs a summary
+// line per run. A failed run is logged, the next one is tried anyway.
+func watch(ctx context.Context, chk *checker.Checker, opts watchOptions) {
+	prevBroken := -1
+	for {
+		start := time.Now()
+		stats, err := chk.Run(ctx)
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if err != nil {
+			log.Printf("error walking the paths %q: %v", chk.Roots, err)
+		}
+		elapsed := time.Since(start)
+		if opts.statsFile != "" {
+			if err := writeStatsFile(opts.statsFile, stats, elapsed); err != nil {
+				log.Printf("Could not write stats file: %v", err)
+			}
+		}
+		if opts.promFile != "" {
+			if err := writePromFile(opts.promFile, stats, elapsed); err != nil {
+				log.Printf("Could not write prom file: %v", err)
+			}
+		}
+		if !opts.onChange || stats.BrokenLinks != prevBroken {
+			if opts.logfmt {
+				log.Print(logfmtSummary(stats, elapsed, opts.dryRun, false))
+			} else {
+				log.Printf("inspected %d, removed %d, broken %d, circular %d, errors %d in %s",
+					stats.LinksInspected, stats.LinksRemoved, stats.BrokenLinks, stats.CircularLinks, stats.Errors, elapsed)
+			}
+		}
+		prevBroken = stats.BrokenLinks
+
+		timer := time.NewTimer(opts.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}